@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// version of gripmock, set at build time with -ldflags "-X main.version=x.y.z"
+var version = ""
+
+// cachedServerPath is where the server generated from param is cached within cacheDir
+func cachedServerPath(cacheDir string, param protocParam) (string, error) {
+	key, err := cacheKey(param, buildVersion())
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, key, "server"), nil
+}
+
+// cacheKey hashes the inputs of the generated server: the protos, descriptor sets, import paths
+// with the protos they hold and server template along with the gripmock version, so that any change
+// of them builds a new server
+func cacheKey(param protocParam, version string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "version %s\n", version)
+
+	imports := append([]string{}, param.imports...)
+	sort.Strings(imports)
+	for _, dir := range imports {
+		fmt.Fprintf(h, "import %s\n", dir)
+		if err := hashProtos(h, dir); err != nil {
+			return "", err
+		}
+	}
+
+	files := []string{}
+	for _, proto := range param.protoPath {
+		_, paths := getProtoDirAndPath(proto)
+		files = append(files, paths...)
+	}
+	files = append(files, param.descriptorSets...)
+	sort.Strings(files)
+
+	for _, file := range files {
+		if err := hashFile(h, file); err != nil {
+			return "", err
+		}
+	}
+
+	// the server template the generated registration is built with
+	for _, name := range []string{"server.go", "go.mod"} {
+		file := filepath.Join(param.output, name)
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		if err := hashFile(h, file); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(h io.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("fail to hash %s: %w", file, err)
+	}
+	defer f.Close()
+
+	fmt.Fprintf(h, "file %s\n", file)
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("fail to hash %s: %w", file, err)
+	}
+	return nil
+}
+
+// hashProtos hashes every proto under the import path dir, in lexical order.
+// Import paths which do not exist import nothing.
+func hashProtos(h io.Writer, dir string) error {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("fail to hash %s: %w", path, err)
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".proto" {
+			return nil
+		}
+		return hashFile(h, path)
+	})
+}
+
+// buildVersion is the gripmock version, or without one a hash of the gripmock
+// and protoc-gen-gripmock binaries, which generate the server
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+
+	h := sha256.New()
+	binaries := []string{}
+	if exe, err := os.Executable(); err == nil {
+		binaries = append(binaries, exe)
+	}
+	if plugin, err := exec.LookPath("protoc-gen-gripmock"); err == nil {
+		binaries = append(binaries, plugin)
+	}
+	for _, binary := range binaries {
+		_ = hashFile(h, binary)
+	}
+	return "dev-" + hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_cacheKey(t *testing.T) {
+	dir := t.TempDir()
+	proto := filepath.Join(dir, "hello.proto")
+	if err := os.WriteFile(proto, []byte(`syntax = "proto3";`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	param := protocParam{protoPath: []string{dir}, imports: []string{"/protobuf"}, output: dir}
+	key, err := cacheKey(param, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	same, _ := cacheKey(param, "1.0.0")
+	if same != key {
+		t.Errorf("cacheKey() = %v, want %v for the same inputs", same, key)
+	}
+
+	otherVersion, _ := cacheKey(param, "1.0.1")
+	otherImports, _ := cacheKey(protocParam{protoPath: []string{dir}, imports: []string{"/protobuf", "/include"}, output: dir}, "1.0.0")
+	if err := os.WriteFile(proto, []byte(`syntax = "proto3"; package hello;`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	otherContent, _ := cacheKey(param, "1.0.0")
+	if err := os.WriteFile(filepath.Join(dir, "server.go"), []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	otherTemplate, _ := cacheKey(param, "1.0.0")
+
+	for name, other := range map[string]string{
+		"version":  otherVersion,
+		"imports":  otherImports,
+		"content":  otherContent,
+		"template": otherTemplate,
+	} {
+		if other == key {
+			t.Errorf("cacheKey() did not change with the %s", name)
+		}
+	}
+
+	// protos of the import paths are hashed along with the input protos
+	include := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(include, "dep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dep := filepath.Join(include, "dep", "dep.proto")
+	if err := os.WriteFile(dep, []byte(`syntax = "proto3"; package dep;`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	withImport := protocParam{protoPath: []string{dir}, imports: []string{include}, output: dir}
+	importKey, err := cacheKey(withImport, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dep, []byte(`syntax = "proto3"; package dep; message Dep {}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if otherImport, _ := cacheKey(withImport, "1.0.0"); otherImport == importKey {
+		t.Errorf("cacheKey() did not change with the content of an imported proto")
+	}
+
+	if _, err := cacheKey(protocParam{descriptorSets: []string{filepath.Join(dir, "missing.binpb")}}, "1.0.0"); err == nil {
+		t.Errorf("cacheKey() error = nil, want an error for a missing descriptor set")
+	}
+}
@@ -0,0 +1,129 @@
+package dynamic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/linker"
+	// links google/api/annotations.proto and google/api/http.proto for the HTTP/JSON transcoding
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Compile parses proto files in-process, without protoc.
+// Proto names are resolved relative to importPaths. Well known types and the
+// google.api.http annotations are always available.
+func Compile(ctx context.Context, importPaths []string, protos ...string) ([]protoreflect.FileDescriptor, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(protocompile.CompositeResolver{
+			&protocompile.SourceResolver{ImportPaths: importPaths},
+			linkedResolver,
+		}),
+	}
+
+	return compile(ctx, compiler, protos)
+}
+
+// linkedResolver resolves the proto files linked into the binary,
+// when they are not found in the import paths
+var linkedResolver = protocompile.ResolverFunc(func(path string) (protocompile.SearchResult, error) {
+	fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+	return protocompile.SearchResult{Desc: fd}, err
+})
+
+func compile(ctx context.Context, compiler protocompile.Compiler, protos []string) ([]protoreflect.FileDescriptor, error) {
+	files, err := compiler.Compile(ctx, protos...)
+	if err != nil {
+		return nil, err
+	}
+
+	built := new(protoregistry.Files)
+	fds := make([]protoreflect.FileDescriptor, len(files))
+	for i, file := range files {
+		fd, err := rebuild(file, built)
+		if err != nil {
+			return nil, err
+		}
+		fds[i] = fd
+	}
+	return fds, nil
+}
+
+// rebuild builds fd and its imports again from their descriptor protos.
+// The compiler keeps custom options as dynamic messages, rebuilding turns options
+// linked into the binary, e.g. google.api.http, into their generated types.
+func rebuild(fd protoreflect.FileDescriptor, built *protoregistry.Files) (protoreflect.FileDescriptor, error) {
+	// imports resolved from descriptors, linked or already registered, are kept
+	if _, ok := fd.(linker.Result); !ok {
+		return fd, nil
+	}
+	if rebuilt, err := built.FindFileByPath(fd.Path()); err == nil {
+		return rebuilt, nil
+	}
+
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		if _, err := rebuild(imports.Get(i).FileDescriptor, built); err != nil {
+			return nil, err
+		}
+	}
+
+	byt, err := proto.Marshal(protodesc.ToFileDescriptorProto(fd))
+	if err != nil {
+		return nil, err
+	}
+	fdp := new(descriptorpb.FileDescriptorProto)
+	if err := proto.Unmarshal(byt, fdp); err != nil {
+		return nil, err
+	}
+
+	rebuilt, err := protodesc.NewFile(fdp, resolvers{built, protoregistry.GlobalFiles})
+	if err != nil {
+		return nil, fmt.Errorf("proto file %s: %w", fd.Path(), err)
+	}
+	if err := built.RegisterFile(rebuilt); err != nil {
+		return nil, err
+	}
+	return rebuilt, nil
+}
+
+// RegisterProtos compiles proto sources, keyed by their import path, and registers them.
+// Imports are resolved from the other sources, the files already registered
+// and the import paths of the registry, in that order.
+// It returns the services declared in the sources.
+func (r *Registry) RegisterProtos(sources map[string]string) ([]string, error) {
+	protos := make([]string, 0, len(sources))
+	for name := range sources {
+		protos = append(protos, name)
+	}
+	sort.Strings(protos)
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(protocompile.CompositeResolver{
+			&protocompile.SourceResolver{Accessor: protocompile.SourceAccessorFromMap(sources)},
+			protocompile.ResolverFunc(func(path string) (protocompile.SearchResult, error) {
+				fd, err := r.FindFileByPath(path)
+				return protocompile.SearchResult{Desc: fd}, err
+			}),
+			&protocompile.SourceResolver{ImportPaths: r.importPaths},
+			linkedResolver,
+		}),
+	}
+
+	fds, err := compile(context.Background(), compiler, protos)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.Register(fds...); err != nil {
+		return nil, err
+	}
+
+	return fileServices(fds), nil
+}
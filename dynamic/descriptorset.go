@@ -0,0 +1,106 @@
+package dynamic
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// RegisterDescriptorSet registers the files of a binary FileDescriptorSet,
+// as produced by protoc --descriptor_set_out or buf build.
+// It returns the services declared in the set.
+func (r *Registry) RegisterDescriptorSet(byt []byte) ([]string, error) {
+	fds, err := r.parseDescriptorSet(byt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.Register(fds...); err != nil {
+		return nil, err
+	}
+
+	return fileServices(fds), nil
+}
+
+// parseDescriptorSet builds the files of the set. Imports missing from the set,
+// e.g. when it was built without --include_imports, are looked up in the registry
+// and then in the well known types linked into the binary.
+func (r *Registry) parseDescriptorSet(byt []byte) ([]protoreflect.FileDescriptor, error) {
+	set := new(descriptorpb.FileDescriptorSet)
+	if err := proto.Unmarshal(byt, set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+
+	protos := map[string]*descriptorpb.FileDescriptorProto{}
+	for _, fdp := range set.GetFile() {
+		protos[fdp.GetName()] = fdp
+	}
+
+	built := new(protoregistry.Files)
+	fds := make([]protoreflect.FileDescriptor, 0, len(protos))
+	var build func(name string, chain []string) error
+	build = func(name string, chain []string) error {
+		if _, err := built.FindFileByPath(name); err == nil {
+			return nil
+		}
+		for _, seen := range chain {
+			if seen == name {
+				return fmt.Errorf("import cycle in descriptor set: %v", append(chain, name))
+			}
+		}
+
+		fdp := protos[name]
+		for _, dep := range fdp.GetDependency() {
+			if _, ok := protos[dep]; !ok {
+				continue
+			}
+			if err := build(dep, append(chain, name)); err != nil {
+				return err
+			}
+		}
+
+		fd, err := protodesc.NewFile(fdp, resolvers{built, r, protoregistry.GlobalFiles})
+		if err != nil {
+			return fmt.Errorf("descriptor set file %s: %w", name, err)
+		}
+		if err := built.RegisterFile(fd); err != nil {
+			return err
+		}
+
+		fds = append(fds, fd)
+		return nil
+	}
+
+	for _, fdp := range set.GetFile() {
+		if err := build(fdp.GetName(), nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return fds, nil
+}
+
+// resolvers looks up descriptors in each resolver in turn
+type resolvers []protodesc.Resolver
+
+func (rs resolvers) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	for _, r := range rs {
+		if fd, err := r.FindFileByPath(path); err == nil {
+			return fd, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+func (rs resolvers) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	for _, r := range rs {
+		if d, err := r.FindDescriptorByName(name); err == nil {
+			return d, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
@@ -0,0 +1,235 @@
+// Package dynamic serves gRPC services straight from their descriptors,
+// so a mock server can run without generating and compiling Go code.
+package dynamic
+
+import (
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Registry holds the proto files served by the dynamic server.
+// It is safe for concurrent use, files can be registered while serving.
+type Registry struct {
+	mx sync.RWMutex
+
+	// importPaths resolve imports of the protos registered at runtime
+	importPaths []string
+
+	// below represent map[filepath]descriptor
+	files    map[string]protoreflect.FileDescriptor
+	resolver *protoregistry.Files
+	types    *dynamicpb.Types
+	// below represent map[/package.Service/Method]descriptor
+	methods map[string]protoreflect.MethodDescriptor
+}
+
+// NewRegistry creates an empty registry. importPaths are searched
+// for the imports of protos registered with RegisterProtos.
+func NewRegistry(importPaths ...string) *Registry {
+	r := &Registry{
+		importPaths: importPaths,
+		files:       map[string]protoreflect.FileDescriptor{},
+	}
+	r.rebuild()
+	return r
+}
+
+// Register adds the files and their imports to the registry.
+// A file already registered under the same path is replaced.
+func (r *Registry) Register(files ...protoreflect.FileDescriptor) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	old := r.files
+	r.files = make(map[string]protoreflect.FileDescriptor, len(old)+len(files))
+	for path, fd := range old {
+		r.files[path] = fd
+	}
+	for _, fd := range files {
+		r.files[fd.Path()] = fd
+		r.addImports(fd)
+	}
+
+	if err := r.rebuild(); err != nil {
+		r.files = old
+		r.rebuild()
+		return err
+	}
+
+	return nil
+}
+
+// RegisterFiles adds every file of a protoregistry.Files to the registry
+func (r *Registry) RegisterFiles(files *protoregistry.Files) error {
+	fds := make([]protoreflect.FileDescriptor, 0, files.NumFiles())
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		fds = append(fds, fd)
+		return true
+	})
+
+	return r.Register(fds...)
+}
+
+func (r *Registry) addImports(fd protoreflect.FileDescriptor) {
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		imp := imports.Get(i).FileDescriptor
+		if _, ok := r.files[imp.Path()]; ok {
+			continue
+		}
+		r.files[imp.Path()] = imp
+		r.addImports(imp)
+	}
+}
+
+func (r *Registry) rebuild() error {
+	resolver := new(protoregistry.Files)
+	methods := map[string]protoreflect.MethodDescriptor{}
+	for _, fd := range r.files {
+		if err := resolver.RegisterFile(fd); err != nil {
+			return err
+		}
+
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			sd := services.Get(i)
+			for j := 0; j < sd.Methods().Len(); j++ {
+				md := sd.Methods().Get(j)
+				methods["/"+string(sd.FullName())+"/"+string(md.Name())] = md
+			}
+		}
+	}
+
+	r.resolver = resolver
+	r.types = dynamicpb.NewTypes(resolver)
+	r.methods = methods
+	return nil
+}
+
+// Services returns the fully qualified names of the registered services
+func (r *Registry) Services() []string {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	seen := map[string]bool{}
+	services := []string{}
+	for _, md := range r.methods {
+		name := string(md.Parent().FullName())
+		if !seen[name] {
+			seen[name] = true
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+// fileServices returns the fully qualified names of the services declared in files
+func fileServices(files []protoreflect.FileDescriptor) []string {
+	services := []string{}
+	for _, fd := range files {
+		for i := 0; i < fd.Services().Len(); i++ {
+			services = append(services, string(fd.Services().Get(i).FullName()))
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+func (r *Registry) findMethod(fullMethod string) (protoreflect.MethodDescriptor, bool) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	md, ok := r.methods[fullMethod]
+	return md, ok
+}
+
+// GetServiceInfo lists the registered services for the reflection service
+func (r *Registry) GetServiceInfo() map[string]grpc.ServiceInfo {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	info := map[string]grpc.ServiceInfo{}
+	for _, md := range r.methods {
+		sd := md.Parent().(protoreflect.ServiceDescriptor)
+		svc := info[string(sd.FullName())]
+		svc.Metadata = sd.ParentFile().Path()
+		svc.Methods = append(svc.Methods, grpc.MethodInfo{
+			Name:           string(md.Name()),
+			IsClientStream: md.IsStreamingClient(),
+			IsServerStream: md.IsStreamingServer(),
+		})
+		info[string(sd.FullName())] = svc
+	}
+	return info
+}
+
+func (r *Registry) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	return r.resolver.FindFileByPath(path)
+}
+
+func (r *Registry) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	return r.resolver.FindDescriptorByName(name)
+}
+
+func (r *Registry) FindMessageByName(message protoreflect.FullName) (protoreflect.MessageType, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	return r.types.FindMessageByName(message)
+}
+
+func (r *Registry) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	return r.types.FindMessageByURL(url)
+}
+
+func (r *Registry) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	return r.types.FindExtensionByName(field)
+}
+
+func (r *Registry) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	return r.types.FindExtensionByNumber(message, field)
+}
+
+func (r *Registry) RangeExtensionsByMessage(message protoreflect.FullName, f func(protoreflect.ExtensionType) bool) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	for _, fd := range r.files {
+		if !rangeExtensions(fd.Extensions(), fd.Messages(), message, f) {
+			return
+		}
+	}
+}
+
+// rangeExtensions walks extensions declared at file level and inside messages
+func rangeExtensions(exts protoreflect.ExtensionDescriptors, msgs protoreflect.MessageDescriptors, message protoreflect.FullName, f func(protoreflect.ExtensionType) bool) bool {
+	for i := 0; i < exts.Len(); i++ {
+		ext := exts.Get(i)
+		if ext.ContainingMessage().FullName() == message && !f(dynamicpb.NewExtensionType(ext)) {
+			return false
+		}
+	}
+
+	for i := 0; i < msgs.Len(); i++ {
+		if !rangeExtensions(msgs.Get(i).Extensions(), msgs.Get(i).Messages(), message, f) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package dynamic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/tokopedia/gripmock/protogen/example/simple"
+)
+
+func TestRegistry_RegisterProtos(t *testing.T) {
+	r := NewRegistry("../example/multi-package")
+
+	services, err := r.RegisterProtos(map[string]string{
+		"greeter/greeter.proto": `syntax = "proto3";
+package greeter.v1;
+import "google/protobuf/empty.proto";
+import "bar/bar.proto";
+service Greeter {
+  rpc Hello (bar.Bar) returns (google.protobuf.Empty);
+}`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"greeter.v1.Greeter"}, services)
+	assert.Equal(t, []string{"greeter.v1.Greeter"}, r.Services())
+
+	_, ok := r.findMethod("/greeter.v1.Greeter/Hello")
+	assert.True(t, ok)
+
+	// imports of registered files are available to reflection
+	_, err = r.FindFileByPath("bar/bar.proto")
+	assert.NoError(t, err)
+
+	// registering the same path again replaces the file
+	services, err = r.RegisterProtos(map[string]string{
+		"greeter/greeter.proto": `syntax = "proto3";
+package greeter.v2;
+service Greeter {
+  rpc Bye (Empty) returns (Empty);
+}
+message Empty {}`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"greeter.v2.Greeter"}, services)
+	assert.Equal(t, []string{"greeter.v2.Greeter"}, r.Services())
+
+	_, err = r.RegisterProtos(map[string]string{"broken.proto": `syntax = "proto3"; message {`})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"greeter.v2.Greeter"}, r.Services())
+}
+
+func TestRegistry_RegisterDescriptorSet(t *testing.T) {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(simple.File_simple_proto)},
+	}
+	byt, err := proto.Marshal(set)
+	require.NoError(t, err)
+
+	r := NewRegistry()
+	services, err := r.RegisterDescriptorSet(byt)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"simple.Gripmock"}, services)
+
+	_, ok := r.findMethod("/simple.Gripmock/SayHello")
+	assert.True(t, ok)
+
+	_, err = r.RegisterDescriptorSet([]byte("not a descriptor set"))
+	assert.Error(t, err)
+}
+
+func TestRegistry_RegisterProtosHTTPRule(t *testing.T) {
+	r := NewRegistry()
+
+	_, err := r.RegisterProtos(map[string]string{
+		"shop.proto": `syntax = "proto3";
+package shop;
+import "google/api/annotations.proto";
+service Shop {
+  rpc GetItem (Item) returns (Item) {
+    option (google.api.http) = { get: "/v1/items/{id}" };
+  }
+}
+message Item { string id = 1; }`,
+	})
+	require.NoError(t, err)
+
+	md, ok := r.findMethod("/shop.Shop/GetItem")
+	require.True(t, ok)
+
+	// the option is available with its generated type, as the transcoding expects
+	rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+	require.True(t, ok)
+	assert.Equal(t, "/v1/items/{id}", rule.GetGet())
+}
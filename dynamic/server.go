@@ -0,0 +1,118 @@
+package dynamic
+
+import (
+	"io"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	v1reflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	v1alphareflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/tokopedia/gripmock/stub"
+)
+
+// NewServer creates a gRPC server answering every method of the registry from the stubs.
+// Reflection is served from the registry as well, and the health service
+// unless the registered protos declare it.
+func NewServer(r *Registry, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.UnknownServiceHandler(r.handle))
+	s := grpc.NewServer(opts...)
+
+	if !slices.Contains(r.Services(), "grpc.health.v1.Health") {
+		stub.RegisterHealth(s)
+	}
+
+	reflectionOpts := reflection.ServerOptions{
+		Services:           r,
+		DescriptorResolver: r,
+		ExtensionResolver:  r,
+	}
+	v1reflectiongrpc.RegisterServerReflectionServer(s, reflection.NewServerV1(reflectionOpts))
+	v1alphareflectiongrpc.RegisterServerReflectionServer(s, reflection.NewServer(reflectionOpts))
+
+	return s
+}
+
+// handle follows the same flow as the generated server for each kind of method
+func (r *Registry) handle(_ interface{}, srv grpc.ServerStream) error {
+	fullMethod, _ := grpc.MethodFromServerStream(srv)
+	md, ok := r.findMethod(fullMethod)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+	}
+
+	switch {
+	case md.IsStreamingClient() && md.IsStreamingServer():
+		return bidirectional(md, srv)
+	case md.IsStreamingClient():
+		return clientStream(md, srv)
+	default:
+		// unary and server stream both receive one message and send one reply
+		return standard(md, srv)
+	}
+}
+
+func findStub(md protoreflect.MethodDescriptor, srv grpc.ServerStream, in, out *dynamicpb.Message) error {
+	headers, _ := metadata.FromIncomingContext(srv.Context())
+	return stub.FindStub(srv.Context(), string(md.Parent().FullName()), string(md.Name()), headers, in, out)
+}
+
+func standard(md protoreflect.MethodDescriptor, srv grpc.ServerStream) error {
+	in := dynamicpb.NewMessage(md.Input())
+	if err := srv.RecvMsg(in); err != nil {
+		return err
+	}
+
+	out := dynamicpb.NewMessage(md.Output())
+	if err := findStub(md, srv, in, out); err != nil {
+		return err
+	}
+
+	return srv.SendMsg(out)
+}
+
+func clientStream(md protoreflect.MethodDescriptor, srv grpc.ServerStream) error {
+	out := dynamicpb.NewMessage(md.Output())
+	for {
+		in := dynamicpb.NewMessage(md.Input())
+		err := srv.RecvMsg(in)
+		if err == io.EOF {
+			return srv.SendMsg(out)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := findStub(md, srv, in, out); err != nil {
+			return err
+		}
+	}
+}
+
+func bidirectional(md protoreflect.MethodDescriptor, srv grpc.ServerStream) error {
+	for {
+		in := dynamicpb.NewMessage(md.Input())
+		err := srv.RecvMsg(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		out := dynamicpb.NewMessage(md.Output())
+		if err := findStub(md, srv, in, out); err != nil {
+			return err
+		}
+
+		if err := srv.SendMsg(out); err != nil {
+			return err
+		}
+	}
+}
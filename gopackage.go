@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/bufbuild/protocompile/ast"
+	"github.com/bufbuild/protocompile/parser"
+	"github.com/bufbuild/protocompile/reporter"
+)
+
+// fixGoPackage copies the protos under protogen/, the same directory tree as their path,
+// with their go_package replaced by our own protogen package. It returns the copied paths.
+func fixGoPackage(protoPaths []string) ([]string, error) {
+	fixed := make([]string, 0, len(protoPaths))
+	for _, proto := range protoPaths {
+		stat, err := os.Stat(proto)
+		if err != nil {
+			return nil, fmt.Errorf("fail to stat proto %s: %w", proto, err)
+		}
+		if stat.IsDir() {
+			continue
+		}
+
+		// example proto: example/foo/bar/hello.proto, dir: example/foo/bar
+		dir := strings.TrimLeft(path.Dir(proto), "/")
+		newPath := path.Join("protogen", dir, path.Base(proto))
+
+		src, err := os.ReadFile(proto)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read proto %s: %w", proto, err)
+		}
+
+		out, err := rewriteGoPackage(proto, src, "github.com/tokopedia/gripmock/protogen/"+dir)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := os.MkdirAll(path.Dir(newPath), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("fail to create protogen dir for %s: %w", proto, err)
+		}
+		if err := os.WriteFile(newPath, out, 0644); err != nil {
+			return nil, fmt.Errorf("fail to write proto %s: %w", newPath, err)
+		}
+		fixed = append(fixed, newPath)
+	}
+
+	return fixed, nil
+}
+
+// rewriteGoPackage removes any go_package option of the proto source and declares goPackage instead,
+// right after the syntax or edition declaration, on the same line so that line numbers are kept
+func rewriteGoPackage(filename string, src []byte, goPackage string) ([]byte, error) {
+	file, err := parser.Parse(filename, bytes.NewReader(src), reporter.NewHandler(nil))
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse proto: %w", err)
+	}
+
+	out := &bytes.Buffer{}
+	last := 0
+	for _, decl := range file.Decls {
+		opt, ok := decl.(*ast.OptionNode)
+		if !ok || !isGoPackage(opt) {
+			continue
+		}
+
+		start := file.NodeInfo(opt).Start().Offset
+		end := file.NodeInfo(opt.Semicolon).Start().Offset + 1
+		out.Write(src[last:start])
+		// keep the line breaks of options spanning several lines
+		out.WriteString(strings.Repeat("\n", bytes.Count(src[start:end], []byte("\n"))))
+		last = end
+	}
+	out.Write(src[last:])
+	removed := out.Bytes()
+
+	option := fmt.Sprintf("option go_package = %q;", goPackage)
+
+	// without a syntax or edition declaration, the option can only come first
+	var declaration ast.Node
+	if file.Syntax != nil {
+		declaration = file.Syntax.Semicolon
+	} else if file.Edition != nil {
+		declaration = file.Edition.Semicolon
+	}
+	if declaration == nil {
+		return append([]byte(option+" "), removed...), nil
+	}
+
+	// the declaration precedes any option, so its offset is the same after the removal
+	at := file.NodeInfo(declaration).Start().Offset + 1
+	fixed := make([]byte, 0, len(removed)+len(option)+1)
+	fixed = append(fixed, removed[:at]...)
+	fixed = append(fixed, " "+option...)
+	return append(fixed, removed[at:]...), nil
+}
+
+func isGoPackage(opt *ast.OptionNode) bool {
+	if len(opt.Name.Parts) != 1 {
+		return false
+	}
+
+	part := opt.Name.Parts[0]
+	return !part.IsExtension() && part.Name.AsIdentifier() == "go_package"
+}
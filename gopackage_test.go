@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_rewriteGoPackage(t *testing.T) {
+	const goPackage = "github.com/tokopedia/gripmock/protogen/example"
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "syntax",
+			src:  "syntax = \"proto3\";\n\noption go_package = \"example.com/hello\";\n\npackage hello;\n",
+			want: "syntax = \"proto3\"; option go_package = \"github.com/tokopedia/gripmock/protogen/example\";\n\n\n\npackage hello;\n",
+		},
+		{
+			name: "option spanning lines",
+			src:  "syntax = \"proto3\";\noption\n  go_package =\n  \"example.com/hello\";\npackage hello;\n",
+			want: "syntax = \"proto3\"; option go_package = \"github.com/tokopedia/gripmock/protogen/example\";\n\n\n\npackage hello;\n",
+		},
+		{
+			name: "comments",
+			src:  "// option go_package = \"example.com/comment\";\nsyntax = \"proto3\"; // proto3\n/* go_package */ option go_package = \"example.com/hello\"; // trailing\npackage hello;\n",
+			want: "// option go_package = \"example.com/comment\";\nsyntax = \"proto3\"; option go_package = \"github.com/tokopedia/gripmock/protogen/example\"; // proto3\n/* go_package */  // trailing\npackage hello;\n",
+		},
+		{
+			name: "edition",
+			src:  "edition = \"2023\";\npackage hello;\noption go_package = \"example.com/hello\";\n",
+			want: "edition = \"2023\"; option go_package = \"github.com/tokopedia/gripmock/protogen/example\";\npackage hello;\n\n",
+		},
+		{
+			name: "no syntax",
+			src:  "package hello;\noption java_package = \"com.example.hello\";\n",
+			want: "option go_package = \"github.com/tokopedia/gripmock/protogen/example\"; package hello;\noption java_package = \"com.example.hello\";\n",
+		},
+		{
+			name: "extension named go_package",
+			src:  "syntax = \"proto3\";\noption (custom.go_package) = \"example.com/hello\";\n",
+			want: "syntax = \"proto3\"; option go_package = \"github.com/tokopedia/gripmock/protogen/example\";\noption (custom.go_package) = \"example.com/hello\";\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rewriteGoPackage("hello.proto", []byte(tt.src), goPackage)
+			if err != nil {
+				t.Fatalf("rewriteGoPackage() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("rewriteGoPackage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, err := rewriteGoPackage("broken.proto", []byte("syntax = \"proto3\";\nmessage {\n"), goPackage)
+	if err == nil || !strings.Contains(err.Error(), "broken.proto:2") {
+		t.Errorf("rewriteGoPackage() error = %v, want the position of the syntax error", err)
+	}
+}
+
+func Test_fixGoPackage(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("example/hello", os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("example/hello/hello.proto", []byte("syntax = \"proto3\";\npackage hello;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := fixGoPackage([]string{"example/hello", "example/hello/hello.proto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != "protogen/example/hello/hello.proto" {
+		t.Fatalf("fixGoPackage() = %v, want [protogen/example/hello/hello.proto]", paths)
+	}
+
+	byt, err := os.ReadFile(filepath.Join(dir, paths[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(byt), `option go_package = "github.com/tokopedia/gripmock/protogen/example/hello";`) {
+		t.Errorf("fixGoPackage() wrote %q", byt)
+	}
+
+	if _, err := fixGoPackage([]string{"example/missing.proto"}); err == nil {
+		t.Errorf("fixGoPackage() error = nil, want an error for a missing proto")
+	}
+}
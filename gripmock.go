@@ -1,10 +1,11 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -13,21 +14,61 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/tokopedia/gripmock/dynamic"
+	"github.com/tokopedia/gripmock/stub"
 )
 
 func main() {
 	outputPointer := flag.String("o", "", "directory to output server.go. Default is $GOPATH/src/grpc/")
 	imports := flag.String("imports", "/protobuf", "comma separated imports path. default path /protobuf is where gripmock Dockerfile install WKT protos")
+	descriptorSets := flag.String("descriptor-sets", "", "comma separated paths of binary FileDescriptorSet files, as produced by protoc --descriptor_set_out or buf build -o, to serve along with or instead of .proto files")
+	cacheDir := flag.String("cache-dir", os.Getenv("GRIPMOCK_CACHE_DIR"), "Directory to cache the generated servers in, keyed by the protos, so that restarts with the same protos skip code generation and compilation. Defaults to $GRIPMOCK_CACHE_DIR, caching is disabled when not set")
+	dynamicMode := flag.Bool("dynamic", false, "Parse protos at runtime and serve them from their descriptors instead of generating and compiling a Go server")
 
 	serverParam := serverParam{}
-	flag.StringVar(&serverParam.grpcAddress, "grpc-listen", "", "Address the gRPC server will bind to. Default to localhost, set to 0.0.0.0 to use from another machine")
+	flag.StringVar(&serverParam.grpcAddress, "grpc-listen", "", "Address the gRPC server will bind to. Default to localhost, set to 0.0.0.0 to use from another machine, or unix:///path/to.sock for a unix socket")
 	flag.Int64Var(&serverParam.grpcPort, "grpc-port", 4770, "BindPort of gRPC tcp server")
-	flag.StringVar(&serverParam.adminAddress, "admin-listen", "", "Address the admin server will bind to. Default to localhost, set to 0.0.0.0 to use from another machine")
+	flag.StringVar(&serverParam.adminAddress, "admin-listen", "", "Address the admin server will bind to. Default to localhost, set to 0.0.0.0 to use from another machine, or unix:///path/to.sock for a unix socket")
 	flag.Int64Var(&serverParam.adminPort, "admin-port", 4771, "BindPort of stub admin server")
 	flag.StringVar(&serverParam.stubPath, "stub", "/stubs", "Path where the stub files are (Optional)")
+	flag.StringVar(&serverParam.tls.CertFile, "tls-cert", "", "Certificate file to serve gRPC over TLS")
+	flag.StringVar(&serverParam.tls.KeyFile, "tls-key", "", "Private key file of the TLS certificate")
+	flag.StringVar(&serverParam.tls.ClientCAFile, "tls-client-ca", "", "CA file to verify client certificates against, enables mutual TLS")
+	flag.StringVar(&serverParam.tls.GenerateDir, "tls-generate-dir", "", "Directory to write a generated self-signed CA, server and client certificates to, the server then uses the generated certificate")
+	flag.StringVar(&serverParam.adminTLS.CertFile, "admin-tls-cert", "", "Certificate file to serve the admin API over TLS")
+	flag.StringVar(&serverParam.adminTLS.KeyFile, "admin-tls-key", "", "Private key file of the admin TLS certificate")
+	flag.StringVar(&serverParam.adminTLS.ClientCAFile, "admin-tls-client-ca", "", "CA file to verify admin client certificates against, enables mutual TLS")
+	flag.StringVar(&serverParam.adminTLS.GenerateDir, "admin-tls-generate-dir", "", "Directory to write a generated self-signed CA, server and client certificates to, the admin API then uses the generated certificate")
+	flag.StringVar(&serverParam.adminToken, "admin-token", os.Getenv("GRIPMOCK_ADMIN_TOKEN"), "Bearer token required by the admin API, defaults to $GRIPMOCK_ADMIN_TOKEN")
+	flag.StringVar(&serverParam.adminBasicAuth, "admin-basic-auth", os.Getenv("GRIPMOCK_ADMIN_BASIC_AUTH"), "user:password basic auth credentials required by the admin API, defaults to $GRIPMOCK_ADMIN_BASIC_AUTH")
+	flag.BoolVar(&serverParam.adminReadOnly, "admin-read-only", false, "Only expose GET /, GET /requests and GET /metrics on the admin API")
+	flag.StringVar(&serverParam.grpcWeb.BindAddr, "grpc-web-listen", "", "Address the gRPC-Web server will bind to, or unix:///path/to.sock for a unix socket")
+	flag.Int64Var(&serverParam.grpcWeb.BindPort, "grpc-web-port", 0, "BindPort of the gRPC-Web server, gRPC-Web is disabled when not set")
+	flag.StringVar(&serverParam.connect.BindAddr, "connect-listen", "", "Address the Connect server will bind to, or unix:///path/to.sock for a unix socket")
+	flag.Int64Var(&serverParam.connect.BindPort, "connect-port", 0, "BindPort of the Connect protocol and HTTP/JSON transcoding server, disabled when not set")
+	flag.StringVar(&serverParam.logLevel, "log-level", "info", "Log level, one of debug, info, warn or error")
+	flag.StringVar(&serverParam.logFormat, "log-format", "text", "Log format, text or json")
+	flag.DurationVar(&serverParam.drainTimeout, "drain-timeout", 5*time.Second, "How long in-flight calls may take to finish on SIGTERM or SIGINT before they are closed")
+	flag.StringVar(&serverParam.journalDump, "journal-dump", "", "File to write the recorded requests to on shutdown, as listed by GET /requests (Optional)")
+	flag.StringVar(&serverParam.fallback, "fallback", "", "Answer calls no stub matches instead of failing them, with defaults or fake values: a mode for every service, e.g. fake, or comma separated service=mode, e.g. hello.Greeter=fake,Health=defaults")
+	flag.Int64Var(&serverParam.fallbackSeed, "fallback-seed", 0, "Seed of the fake values of fallback responses, the same seed gives the same responses")
+	flag.StringVar(&serverParam.notFound, "not-found", "", "gRPC code, by name or number, of the calls no stub matches, UNKNOWN when not set, or default to answer with the default stub of the method, optionally followed by the code for methods without one, e.g. default:NOT_FOUND: a mode for every service, e.g. NOT_FOUND, or comma separated service=mode, e.g. hello.Greeter=default:NOT_FOUND,UNIMPLEMENTED")
+	flag.BoolVar(&serverParam.notFoundShortMessage, "not-found-short-message", false, "Return a short message for calls no stub matches, with the diagnostic in the error details")
+	flag.StringVar(&serverParam.otlpEndpoint, "otlp-endpoint", "", "OTLP/gRPC endpoint to export traces of the mocked calls to, e.g. http://localhost:4317, tracing is disabled when not set")
+	flag.Func("grpc-web-allowed-origins", "Comma separated origins allowed by gRPC-Web CORS, any origin is allowed when not set", func(origins string) error {
+		serverParam.grpcWeb.AllowedOrigins = append(serverParam.grpcWeb.AllowedOrigins, strings.Split(origins, ",")...)
+		return nil
+	})
 
 	if len(os.Args) == 0 {
-		log.Fatal("No arguments were passed")
+		stub.Fatal("No arguments were passed")
 	}
 
 	// for backwards compatibility
@@ -36,55 +77,143 @@ func main() {
 	}
 
 	flag.Parse()
-	fmt.Println("Starting GripMock")
-	if os.Getenv("GOPATH") == "" {
-		log.Fatal("$GOPATH is empty")
-	}
-	output := *outputPointer
-	if output == "" {
-		output = os.Getenv("GOPATH") + "/src/grpc"
-	}
-
-	// for safety
-	output += "/"
-	if _, err := os.Stat(output); os.IsNotExist(err) {
-		os.Mkdir(output, os.ModePerm)
+	if err := stub.SetupLogger(serverParam.logLevel, serverParam.logFormat); err != nil {
+		stub.Fatal("Invalid logging flags", "error", err)
 	}
+	slog.Info("Starting GripMock")
 
 	// parse proto files
 	protoPaths := flag.Args()
 
-	if len(protoPaths) == 0 {
+	var setPaths []string
+	if *descriptorSets != "" {
+		setPaths = strings.Split(*descriptorSets, ",")
+	}
+
+	if len(protoPaths) == 0 && len(setPaths) == 0 {
 		protoPaths = []string{"/proto"}
 	}
 
 	importDirs := strings.Split(*imports, ",")
 
-	// generate pb.go and grpc server based on proto
-	generateProtoc(protocParam{
-		protoPath: protoPaths,
-		output:    output,
-		imports:   importDirs,
-	})
+	var errCh <-chan error
+	var stop func(os.Signal) error
+	if *dynamicMode {
+		registry := loadDynamicProtos(protoPaths, importDirs, setPaths)
+
+		flushTraces := func(context.Context) error { return nil }
+		if serverParam.otlpEndpoint != "" {
+			shutdown, err := stub.SetupTracing(context.Background(), serverParam.otlpEndpoint)
+			if err != nil {
+				stub.Fatal("failed to set up tracing", "error", err)
+			}
+			flushTraces = shutdown
+		}
+
+		grpcServer, runerr := runDynamicServer(serverParam, registry)
+		errCh = runerr
+		stop = func(os.Signal) error {
+			stub.Shutdown(grpcServer, serverParam.drainTimeout)
+			if serverParam.journalDump != "" {
+				if err := stub.DumpJournal(serverParam.journalDump); err != nil {
+					slog.Error("Fail to dump journal", "error", err)
+				}
+			}
+			_ = flushTraces(context.Background())
+			return nil
+		}
+	} else {
+		if os.Getenv("GOPATH") == "" {
+			stub.Fatal("$GOPATH is empty")
+		}
+		output := *outputPointer
+		if output == "" {
+			output = os.Getenv("GOPATH") + "/src/grpc"
+		}
 
-	// and run
-	run, errCh := runGrpcServer(serverParam)
+		// for safety
+		output += "/"
+		if _, err := os.Stat(output); os.IsNotExist(err) {
+			os.Mkdir(output, os.ModePerm)
+		}
+
+		param := protocParam{
+			protoPath:      protoPaths,
+			descriptorSets: setPaths,
+			output:         output,
+			imports:        importDirs,
+		}
+
+		cached := ""
+		if *cacheDir != "" {
+			var err error
+			cached, err = cachedServerPath(*cacheDir, param)
+			if err != nil {
+				stub.Fatal("Fail to compute cache key", "error", err)
+			}
+		}
+
+		if _, err := os.Stat(cached); cached != "" && err == nil {
+			slog.Info("Using cached server", "path", cached)
+		} else {
+			if cached != "" {
+				slog.Info("Caching server", "path", cached)
+			}
+			// generate pb.go and grpc server based on proto
+			generateProtoc(param)
+		}
 
-	term := make(chan os.Signal)
-	signal.Notify(term, syscall.SIGTERM, syscall.SIGKILL, syscall.SIGINT)
+		// and run
+		run, runerr := runGrpcServer(serverParam, cached)
+		errCh = runerr
+		stop = func(sig os.Signal) error {
+			// the server drains its calls and dumps the journal itself
+			if err := run.Process.Signal(sig); err != nil {
+				_ = run.Process.Kill()
+				return err
+			}
+			select {
+			case err := <-errCh:
+				return err
+			case <-time.After(serverParam.drainTimeout + time.Second):
+				_ = run.Process.Kill()
+				return fmt.Errorf("gRPC server pid %d did not stop in time, killed it", run.Process.Pid)
+			}
+		}
+	}
+
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGTERM, syscall.SIGINT)
 	select {
 	case err := <-errCh:
-		log.Fatal(err)
-	case <-term:
-		fmt.Println("Stopping gRPC Server")
-		_ = run.Process.Kill()
+		if err != nil {
+			slog.Error("gRPC server stopped", "error", err)
+			os.Exit(exitCode(err))
+		}
+		slog.Info("gRPC server stopped")
+	case sig := <-term:
+		slog.Info("Stopping gRPC Server", "signal", sig.String())
+		if err := stop(sig); err != nil {
+			slog.Error("gRPC server did not stop cleanly", "error", err)
+			os.Exit(exitCode(err))
+		}
 	}
 }
 
+// exitCode passes on the exit code of the generated server, 1 for any other error
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
 type protocParam struct {
-	protoPath []string
-	output    string
-	imports   []string
+	protoPath      []string
+	descriptorSets []string
+	output         string
+	imports        []string
 }
 
 func getProtodirs(protoPath string, imports []string) []string {
@@ -123,7 +252,10 @@ func generateProtoc(param protocParam) {
 	for _, proto := range param.protoPath {
 		dir, paths := getProtoDirAndPath(proto)
 		dir = "protogen/" + strings.TrimLeft(dir, "/")
-		paths = fixGoPackage(paths)
+		paths, err := fixGoPackage(paths)
+		if err != nil {
+			stub.Fatal("Fail to fix go_package", "error", err)
+		}
 
 		param.imports = append(param.imports, dir)
 		protoPaths = append(protoPaths, paths...)
@@ -131,8 +263,13 @@ func generateProtoc(param protocParam) {
 	param.protoPath = protoPaths
 
 	// estimate args length to prevent expand
-	args := make([]string, 0, len(param.imports)+len(param.protoPath)+2)
-	fmt.Println("Imports:", param.imports)
+	args := make([]string, 0, len(param.imports)+len(param.protoPath)+3)
+	if len(param.descriptorSets) > 0 {
+		setPath, names := fixDescriptorSetGoPackage(param.descriptorSets, param.output)
+		args = append(args, "--descriptor_set_in="+setPath)
+		param.protoPath = append(param.protoPath, names...)
+	}
+	slog.Info("Generating server", "imports", param.imports)
 	for _, dir := range param.imports {
 		args = append(args, "-I", dir)
 	}
@@ -147,15 +284,14 @@ func generateProtoc(param protocParam) {
 	protoc.Stdout = os.Stdout
 	protoc.Stderr = os.Stderr
 	if err := protoc.Run(); err != nil {
-		log.Fatal("Fail on protoc ", err)
+		stub.Fatal("Fail on protoc", "error", err)
 	}
 }
 
 func getProtoDirAndPath(proto string) (protoDir string, protoPaths []string) {
 	stat, err := os.Stat(proto)
 	if err != nil {
-		fmt.Println(proto)
-		log.Fatal(fmt.Errorf("fail to stat proto %s: %w", proto, err))
+		stub.Fatal("Fail to stat proto", "proto", proto, "error", err)
 	}
 	if stat.Mode().IsRegular() {
 		protoDir = path.Dir(proto)
@@ -171,7 +307,7 @@ func getProtoDirAndPath(proto string) (protoDir string, protoPaths []string) {
 func readDirProto(proto string) (protoPaths []string) {
 	entries, err := os.ReadDir(proto)
 	if err != nil {
-		log.Fatal(fmt.Errorf("Error reading dir %s: %w", proto, err))
+		stub.Fatal("Error reading dir", "dir", proto, "error", err)
 	}
 	for _, entry := range entries {
 		name := path.Join(proto, entry.Name())
@@ -187,19 +323,56 @@ func readDirProto(proto string) (protoPaths []string) {
 	return protoPaths
 }
 
-// append gopackage in proto files if doesn't have any
-func fixGoPackage(protoPaths []string) []string {
-	fixgopackage := exec.Command("fix_gopackage.sh", protoPaths...)
-	buf := &bytes.Buffer{}
-	fixgopackage.Stdout = buf
-	fixgopackage.Stderr = os.Stderr
-	err := fixgopackage.Run()
+// fixDescriptorSetGoPackage merges descriptor sets into a single one with our own go_package,
+// the same way fixGoPackage does for .proto files. It returns the path of the merged set
+// and the names of the files protoc should generate code for.
+func fixDescriptorSetGoPackage(setPaths []string, output string) (string, []string) {
+	merged := new(descriptorpb.FileDescriptorSet)
+	seen := map[string]bool{}
+	names := []string{}
+	for _, setPath := range setPaths {
+		byt, err := os.ReadFile(setPath)
+		if err != nil {
+			stub.Fatal("Fail to read descriptor set", "path", setPath, "error", err)
+		}
+
+		set := new(descriptorpb.FileDescriptorSet)
+		if err := proto.Unmarshal(byt, set); err != nil {
+			stub.Fatal("Fail to parse descriptor set", "path", setPath, "error", err)
+		}
+
+		for _, file := range set.GetFile() {
+			if seen[file.GetName()] {
+				continue
+			}
+			seen[file.GetName()] = true
+			merged.File = append(merged.File, file)
+
+			// well known types and googleapis already have generated Go packages
+			if strings.HasPrefix(file.GetOptions().GetGoPackage(), "google.golang.org/") {
+				continue
+			}
+
+			if file.Options == nil {
+				file.Options = new(descriptorpb.FileOptions)
+			}
+			dir := path.Join("descriptorset", path.Dir(file.GetName()))
+			file.Options.GoPackage = proto.String("github.com/tokopedia/gripmock/protogen/" + dir)
+			names = append(names, file.GetName())
+		}
+	}
+
+	byt, err := proto.Marshal(merged)
 	if err != nil {
-		log.Println("error on fixGoPackage", err)
-		return protoPaths
+		stub.Fatal("Fail to write descriptor set", "error", err)
+	}
+
+	setPath := path.Join(output, "descriptor_set.pb")
+	if err := os.WriteFile(setPath, byt, 0644); err != nil {
+		stub.Fatal("Fail to write descriptor set", "error", err)
 	}
 
-	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	return setPath, names
 }
 
 type serverParam struct {
@@ -208,40 +381,130 @@ type serverParam struct {
 	grpcAddress  string
 	grpcPort     int64
 	stubPath     string
+	tls          stub.TLSOptions
+
+	adminTLS       stub.TLSOptions
+	adminToken     string
+	adminBasicAuth string
+	adminReadOnly  bool
+
+	grpcWeb stub.GRPCWebOptions
+	connect stub.ConnectOptions
+
+	drainTimeout time.Duration
+	journalDump  string
+
+	fallback     string
+	fallbackSeed int64
+
+	notFound             string
+	notFoundShortMessage bool
+
+	otlpEndpoint string
+	logLevel     string
+	logFormat    string
 }
 
-func runGrpcServer(params serverParam) (*exec.Cmd, <-chan error) {
+// runGrpcServer builds and starts the generated server with start_server.sh.
+// A non empty cached path is where the built server is kept, it is started directly once built.
+func runGrpcServer(params serverParam, cached string) (*exec.Cmd, <-chan error) {
 	args := []string{
 		"--grpc-port=" + strconv.FormatInt(params.grpcPort, 10),
 		"--admin-port=" + strconv.FormatInt(params.adminPort, 10),
 	}
 	if params.grpcAddress != "" {
-		args = append(args, "--grpc-listen="+params.grpcAddress)
+		args = append(args, "--grpc-listen="+absAddress(params.grpcAddress))
 	}
 	if params.adminAddress != "" {
-		args = append(args, "--admin-listen="+params.adminAddress)
+		args = append(args, "--admin-listen="+absAddress(params.adminAddress))
 	}
 	if params.stubPath != "" {
-		if string(params.stubPath[0]) != "/" {
-			wd, err := os.Getwd()
-			if err != nil {
-				log.Fatal(err)
-			}
+		args = append(args, "--stubs="+absPath(params.stubPath))
+	}
+	// the server runs from its own directory, so every path must be absolute
+	if params.tls.CertFile != "" {
+		args = append(args, "--tls-cert="+absPath(params.tls.CertFile))
+	}
+	if params.tls.KeyFile != "" {
+		args = append(args, "--tls-key="+absPath(params.tls.KeyFile))
+	}
+	if params.tls.ClientCAFile != "" {
+		args = append(args, "--tls-client-ca="+absPath(params.tls.ClientCAFile))
+	}
+	if params.tls.GenerateDir != "" {
+		args = append(args, "--tls-generate-dir="+absPath(params.tls.GenerateDir))
+	}
+	if params.adminTLS.CertFile != "" {
+		args = append(args, "--admin-tls-cert="+absPath(params.adminTLS.CertFile))
+	}
+	if params.adminTLS.KeyFile != "" {
+		args = append(args, "--admin-tls-key="+absPath(params.adminTLS.KeyFile))
+	}
+	if params.adminTLS.ClientCAFile != "" {
+		args = append(args, "--admin-tls-client-ca="+absPath(params.adminTLS.ClientCAFile))
+	}
+	if params.adminTLS.GenerateDir != "" {
+		args = append(args, "--admin-tls-generate-dir="+absPath(params.adminTLS.GenerateDir))
+	}
+	if params.adminReadOnly {
+		args = append(args, "--admin-read-only")
+	}
+	if params.grpcWeb.BindAddr != "" {
+		args = append(args, "--grpc-web-listen="+absAddress(params.grpcWeb.BindAddr))
+	}
+	if params.grpcWeb.BindPort > 0 {
+		args = append(args, "--grpc-web-port="+strconv.FormatInt(params.grpcWeb.BindPort, 10))
+	}
+	if params.connect.BindAddr != "" {
+		args = append(args, "--connect-listen="+absAddress(params.connect.BindAddr))
+	}
+	if params.connect.BindPort > 0 {
+		args = append(args, "--connect-port="+strconv.FormatInt(params.connect.BindPort, 10))
+	}
+	if params.otlpEndpoint != "" {
+		args = append(args, "--otlp-endpoint="+params.otlpEndpoint)
+	}
+	if params.journalDump != "" {
+		args = append(args, "--journal-dump="+absPath(params.journalDump))
+	}
+	if params.fallback != "" {
+		args = append(args, "--fallback="+params.fallback, "--fallback-seed="+strconv.FormatInt(params.fallbackSeed, 10))
+	}
+	if params.notFound != "" {
+		args = append(args, "--not-found="+params.notFound)
+	}
+	if params.notFoundShortMessage {
+		args = append(args, "--not-found-short-message")
+	}
+	args = append(args, "--drain-timeout="+params.drainTimeout.String())
+	args = append(args, "--log-level="+params.logLevel, "--log-format="+params.logFormat)
+	if len(params.grpcWeb.AllowedOrigins) > 0 {
+		args = append(args, "--grpc-web-allowed-origins="+strings.Join(params.grpcWeb.AllowedOrigins, ","))
+	}
 
-			params.stubPath = path.Join(wd, params.stubPath)
+	// credentials go through the environment to keep them out of the process list
+	env := append(os.Environ(),
+		"GRIPMOCK_ADMIN_TOKEN="+params.adminToken,
+		"GRIPMOCK_ADMIN_BASIC_AUTH="+params.adminBasicAuth,
+	)
+	command := "start_server.sh"
+	if cached != "" {
+		if _, err := os.Stat(cached); err == nil {
+			command = cached
+		} else {
+			env = append(env, "GRIPMOCK_SERVER_BIN="+cached)
 		}
-
-		args = append(args, "--stubs="+params.stubPath)
 	}
 
-	run := exec.Command("start_server.sh", args...)
+	run := exec.Command(command, args...)
+	run.Env = env
 	run.Stdout = os.Stdout
 	run.Stderr = os.Stderr
 	err := run.Start()
 	if err != nil {
-		log.Fatal(err)
+		stub.Fatal("Fail to start gRPC server", "error", err)
 	}
-	fmt.Printf("grpc server pid: %d\n", run.Process.Pid)
+	slog.Info("Started gRPC server", "pid", run.Process.Pid)
 	runerr := make(chan error)
 	go func() {
 		runerr <- run.Wait()
@@ -249,3 +512,153 @@ func runGrpcServer(params serverParam) (*exec.Cmd, <-chan error) {
 
 	return run, runerr
 }
+
+func absPath(p string) string {
+	if string(p[0]) == "/" {
+		return p
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		stub.Fatal("Fail to get working directory", "error", err)
+	}
+
+	return path.Join(wd, p)
+}
+
+// absAddress makes the socket path of a unix:// address absolute
+func absAddress(address string) string {
+	socket, ok := strings.CutPrefix(address, "unix://")
+	if !ok || socket == "" {
+		return address
+	}
+
+	return "unix://" + absPath(socket)
+}
+
+// dynamicProtoNames resolves proto files and directories into import paths and
+// proto names relative to them, the way protoc would see them
+func dynamicProtoNames(protoPaths []string) (importPaths []string, names []string) {
+	seenDir := map[string]bool{}
+	seenName := map[string]bool{}
+	for _, proto := range protoPaths {
+		dir, paths := getProtoDirAndPath(proto)
+		if !seenDir[dir] {
+			seenDir[dir] = true
+			importPaths = append(importPaths, dir)
+		}
+
+		for _, p := range paths {
+			name, err := filepath.Rel(dir, p)
+			if err != nil {
+				stub.Fatal("Fail to resolve proto", "proto", p, "error", err)
+			}
+			name = filepath.ToSlash(name)
+			if !seenName[name] {
+				seenName[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	return importPaths, names
+}
+
+func loadDynamicProtos(protoPaths []string, imports []string, descriptorSets []string) *dynamic.Registry {
+	importPaths, names := dynamicProtoNames(protoPaths)
+	importPaths = append(importPaths, imports...)
+	slog.Info("Loading protos", "imports", importPaths)
+
+	registry := dynamic.NewRegistry(importPaths...)
+	for _, setPath := range descriptorSets {
+		byt, err := os.ReadFile(setPath)
+		if err != nil {
+			stub.Fatal("Fail to read descriptor set", "path", setPath, "error", err)
+		}
+		if _, err := registry.RegisterDescriptorSet(byt); err != nil {
+			stub.Fatal("Fail to register descriptor set", "path", setPath, "error", err)
+		}
+	}
+
+	if len(names) > 0 {
+		fds, err := dynamic.Compile(context.Background(), importPaths, names...)
+		if err != nil {
+			stub.Fatal("Fail on parsing protos", "error", err)
+		}
+
+		if err := registry.Register(fds...); err != nil {
+			stub.Fatal("Fail on registering protos", "error", err)
+		}
+	}
+	slog.Info("Loaded protos", "services", registry.Services())
+
+	return registry
+}
+
+// runDynamicServer serves the stub admin and the gRPC server within the gripmock process
+func runDynamicServer(params serverParam, registry *dynamic.Registry) (*grpc.Server, <-chan error) {
+	if params.adminAddress == "" {
+		params.adminAddress = "0.0.0.0"
+	}
+	if params.grpcAddress == "" {
+		params.grpcAddress = "0.0.0.0"
+	}
+
+	stub.RunStubServer(stub.Options{
+		BindAddr: params.adminAddress,
+		BindPort: params.adminPort,
+		StubPath: params.stubPath,
+		Services: registry.Services,
+		Schema:   registry,
+		Protos:   registry,
+
+		TLS:       params.adminTLS,
+		Token:     params.adminToken,
+		BasicAuth: params.adminBasicAuth,
+		ReadOnly:  params.adminReadOnly,
+
+		Fallback:     params.fallback,
+		FallbackSeed: params.fallbackSeed,
+
+		NotFound:             params.notFound,
+		NotFoundShortMessage: params.notFoundShortMessage,
+	})
+
+	lis, addr, err := stub.Listen(params.grpcAddress, params.grpcPort)
+	if err != nil {
+		stub.Fatal("failed to listen", "error", err)
+	}
+
+	opts := stub.ServerOptions()
+	if params.tls.Enabled() {
+		tlsConfig, err := params.tls.Config()
+		if err != nil {
+			stub.Fatal("failed to configure TLS", "error", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	s := dynamic.NewServer(registry, opts...)
+	if params.grpcWeb.Enabled() {
+		if params.grpcWeb.BindAddr == "" {
+			params.grpcWeb.BindAddr = "0.0.0.0"
+		}
+		params.grpcWeb.TLS = params.tls
+		stub.RunGRPCWebServer(s, registry.Services, registry, params.grpcWeb)
+	}
+	if params.connect.Enabled() {
+		if params.connect.BindAddr == "" {
+			params.connect.BindAddr = "0.0.0.0"
+		}
+		params.connect.TLS = params.tls
+		stub.RunConnectServer(s, registry.Services, registry, params.connect)
+	}
+
+	slog.Info("Serving gRPC", "address", addr)
+	runerr := make(chan error)
+	go func() {
+		runerr <- s.Serve(lis)
+	}()
+
+	return s, runerr
+}
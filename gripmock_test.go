@@ -1,8 +1,17 @@
 package main
 
 import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 func Test_getProtodirs(t *testing.T) {
@@ -57,3 +66,106 @@ func Test_getProtodirs(t *testing.T) {
 		})
 	}
 }
+
+func Test_dynamicProtoNames(t *testing.T) {
+	tests := []struct {
+		name            string
+		protoPaths      []string
+		wantImportPaths []string
+		wantNames       []string
+	}{
+		{
+			name:            "single file",
+			protoPaths:      []string{"example/simple/simple.proto"},
+			wantImportPaths: []string{"example/simple"},
+			wantNames:       []string{"simple.proto"},
+		},
+		{
+			name:            "files in the same dir",
+			protoPaths:      []string{"example/multi-files/file1.proto", "example/multi-files/file2.proto"},
+			wantImportPaths: []string{"example/multi-files"},
+			wantNames:       []string{"file1.proto", "file2.proto"},
+		},
+		{
+			name:            "directory",
+			protoPaths:      []string{"example/multi-package/"},
+			wantImportPaths: []string{"example/multi-package/"},
+			wantNames:       []string{"bar/bar.proto", "foo.proto", "hello.proto"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			importPaths, names := dynamicProtoNames(tt.protoPaths)
+			if !reflect.DeepEqual(importPaths, tt.wantImportPaths) {
+				t.Errorf("dynamicProtoNames() importPaths = %v, want %v", importPaths, tt.wantImportPaths)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("dynamicProtoNames() names = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func Test_fixDescriptorSetGoPackage(t *testing.T) {
+	dir := t.TempDir()
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(emptypb.File_google_protobuf_empty_proto),
+			{
+				Name:       proto.String("foo/v1/foo.proto"),
+				Package:    proto.String("foo.v1"),
+				Dependency: []string{"google/protobuf/empty.proto"},
+				Options:    &descriptorpb.FileOptions{GoPackage: proto.String("github.com/my/private/repo/foo")},
+			},
+			{
+				Name:    proto.String("bar.proto"),
+				Package: proto.String("bar"),
+			},
+		},
+	}
+	byt, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setPath := filepath.Join(dir, "set.pb")
+	if err := os.WriteFile(setPath, byt, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, names := fixDescriptorSetGoPackage([]string{setPath, setPath}, dir)
+	if want := []string{"foo/v1/foo.proto", "bar.proto"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("fixDescriptorSetGoPackage() names = %v, want %v", names, want)
+	}
+
+	byt, err = os.ReadFile(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(descriptorpb.FileDescriptorSet)
+	if err := proto.Unmarshal(byt, got); err != nil {
+		t.Fatal(err)
+	}
+
+	goPackages := map[string]string{}
+	for _, file := range got.GetFile() {
+		goPackages[file.GetName()] = file.GetOptions().GetGoPackage()
+	}
+	want := map[string]string{
+		"google/protobuf/empty.proto": "google.golang.org/protobuf/types/known/emptypb",
+		"foo/v1/foo.proto":            "github.com/tokopedia/gripmock/protogen/descriptorset/foo/v1",
+		"bar.proto":                   "github.com/tokopedia/gripmock/protogen/descriptorset",
+	}
+	if !reflect.DeepEqual(goPackages, want) {
+		t.Errorf("fixDescriptorSetGoPackage() go packages = %v, want %v", goPackages, want)
+	}
+}
+
+func Test_exitCode(t *testing.T) {
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	if got := exitCode(err); got != 3 {
+		t.Errorf("exitCode() = %v, want 3", got)
+	}
+	if got := exitCode(errors.New("killed")); got != 1 {
+		t.Errorf("exitCode() = %v, want 1", got)
+	}
+}
@@ -0,0 +1,155 @@
+// Package gripmocktest runs a gripmock server inside a go test process.
+//
+// Services are served from their descriptors, so neither protoc nor a Go build step is needed:
+//
+//	srv := gripmocktest.NewFromProto(t, []string{"proto"}, "hello.proto")
+//	srv.AddStub(&stub.Stub{...})
+//	client := pb.NewGreeterClient(srv.Conn())
+//
+// All servers of a process share gripmock's stub store,
+// so tests using them should not run in parallel.
+package gripmocktest
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/tokopedia/gripmock/dynamic"
+	"github.com/tokopedia/gripmock/stub"
+)
+
+// Server is a mock gRPC server listening on a random local port
+type Server struct {
+	t    testing.TB
+	addr string
+
+	once sync.Once
+	conn *grpc.ClientConn
+}
+
+// New starts a mock server for every service in files.
+// The server is stopped and the stubs are cleared when the test finishes.
+func New(t testing.TB, files *protoregistry.Files) *Server {
+	t.Helper()
+
+	registry := dynamic.NewRegistry()
+	if err := registry.RegisterFiles(files); err != nil {
+		t.Fatalf("gripmocktest: register files: %v", err)
+	}
+
+	return start(t, registry)
+}
+
+// NewFromProto compiles the proto files, resolved against importPaths,
+// and starts a mock server for their services like New does.
+func NewFromProto(t testing.TB, importPaths []string, protos ...string) *Server {
+	t.Helper()
+
+	fds, err := dynamic.Compile(context.Background(), importPaths, protos...)
+	if err != nil {
+		t.Fatalf("gripmocktest: compile protos: %v", err)
+	}
+
+	registry := dynamic.NewRegistry()
+	if err := registry.Register(fds...); err != nil {
+		t.Fatalf("gripmocktest: register files: %v", err)
+	}
+
+	return start(t, registry)
+}
+
+func start(t testing.TB, registry *dynamic.Registry) *Server {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("gripmocktest: listen: %v", err)
+	}
+
+	stub.Clear()
+	stub.SetServices(registry.Services)
+	stub.SetSchema(registry)
+	stub.InitHealth(registry.Services())
+	grpcServer := dynamic.NewServer(registry)
+	go grpcServer.Serve(lis)
+
+	s := &Server{t: t, addr: lis.Addr().String()}
+	t.Cleanup(func() {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		grpcServer.Stop()
+		stub.Clear()
+		stub.SetServices(nil)
+		stub.SetSchema(nil)
+		stub.SetFallback("", 0)
+		stub.SetNotFound("", false)
+	})
+
+	return s
+}
+
+// Addr returns the host:port the server listens on
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Conn returns a client connection to the server, closed when the test finishes
+func (s *Server) Conn() *grpc.ClientConn {
+	s.once.Do(func() {
+		conn, err := grpc.NewClient(s.addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			s.t.Fatalf("gripmocktest: dial %s: %v", s.addr, err)
+		}
+		s.conn = conn
+	})
+
+	return s.conn
+}
+
+// AddStub stores stubs, failing the test if one of them is invalid
+func (s *Server) AddStub(stubs ...*stub.Stub) {
+	s.t.Helper()
+
+	for _, st := range stubs {
+		if err := stub.StoreStub(st); err != nil {
+			s.t.Fatalf("gripmocktest: add stub for %s/%s: %v", st.Service, st.Method, err)
+		}
+	}
+}
+
+// Requests returns the calls received by the server so far
+func (s *Server) Requests() []stub.Request {
+	return stub.Requests()
+}
+
+// Clear removes all stubs and recorded calls
+func (s *Server) Clear() {
+	stub.Clear()
+}
+
+// Fallback answers the calls no stub matches, see stub.SetFallback,
+// failing the test if the config is invalid
+func (s *Server) Fallback(config string, seed int64) {
+	s.t.Helper()
+
+	if err := stub.SetFallback(config, seed); err != nil {
+		s.t.Fatalf("gripmocktest: fallback: %v", err)
+	}
+}
+
+// NotFound sets how the calls no stub matches are answered, see stub.SetNotFound,
+// failing the test if the config is invalid
+func (s *Server) NotFound(config string, shortMessage bool) {
+	s.t.Helper()
+
+	if err := stub.SetNotFound(config, shortMessage); err != nil {
+		s.t.Fatalf("gripmocktest: not found: %v", err)
+	}
+}
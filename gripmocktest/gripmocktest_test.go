@@ -0,0 +1,121 @@
+package gripmocktest
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/tokopedia/gripmock/protogen/example/simple"
+	"github.com/tokopedia/gripmock/protogen/example/stream"
+	"github.com/tokopedia/gripmock/stub"
+)
+
+func TestNew(t *testing.T) {
+	files := new(protoregistry.Files)
+	require.NoError(t, files.RegisterFile(simple.File_simple_proto))
+
+	srv := New(t, files)
+	srv.AddStub(&stub.Stub{
+		Service: "Gripmock",
+		Method:  "SayHello",
+		Input:   stub.Input{Equals: map[string]interface{}{"name": "tokopedia"}},
+		Output:  stub.Output{Data: map[string]interface{}{"message": "Hello Tokopedia"}},
+	})
+
+	client := simple.NewGripmockClient(srv.Conn())
+	reply, err := client.SayHello(context.Background(), &simple.Request{Name: "tokopedia"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Tokopedia", reply.GetMessage())
+
+	// served services are healthy, like on a standalone server
+	health, err := healthpb.NewHealthClient(srv.Conn()).Check(context.Background(), &healthpb.HealthCheckRequest{Service: "simple.Gripmock"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, health.GetStatus())
+
+	_, err = client.SayHello(context.Background(), &simple.Request{Name: "unknown"})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unknown, status.Code(err))
+
+	requests := srv.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "simple.Gripmock", requests[0].Service)
+	assert.Equal(t, "SayHello", requests[0].Method)
+	assert.Equal(t, map[string]interface{}{"name": "tokopedia"}, requests[0].Data)
+}
+
+func TestNewFromProto(t *testing.T) {
+	srv := NewFromProto(t, []string{"../example/stream"}, "stream.proto")
+	srv.AddStub(
+		&stub.Stub{
+			Service: "Gripmock",
+			Method:  "serverStream",
+			Input:   stub.Input{Equals: map[string]interface{}{"name": "server"}},
+			Output:  stub.Output{Data: map[string]interface{}{"message": "server stream"}},
+		},
+		&stub.Stub{
+			Service: "Gripmock",
+			Method:  "bidirectional",
+			Input:   stub.Input{Contains: map[string]interface{}{"name": "bidi"}},
+			Output:  stub.Output{Data: map[string]interface{}{"message": "bidirectional stream"}},
+		},
+	)
+
+	client := stream.NewGripmockClient(srv.Conn())
+	ctx := context.Background()
+
+	serverStream, err := client.ServerStream(ctx, &stream.Request{Name: "server"})
+	require.NoError(t, err)
+	reply, err := serverStream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "server stream", reply.GetMessage())
+	_, err = serverStream.Recv()
+	assert.Equal(t, io.EOF, err)
+
+	bidi, err := client.Bidirectional(ctx)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, bidi.Send(&stream.Request{Name: "bidi"}))
+		reply, err := bidi.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "bidirectional stream", reply.GetMessage())
+	}
+	require.NoError(t, bidi.CloseSend())
+	_, err = bidi.Recv()
+	assert.Equal(t, io.EOF, err)
+
+	srv.Clear()
+	assert.Empty(t, srv.Requests())
+}
+
+func TestFallback(t *testing.T) {
+	srv := NewFromProto(t, []string{"../example/simple"}, "simple.proto")
+	srv.Fallback("fake", 1)
+
+	client := simple.NewGripmockClient(srv.Conn())
+	reply, err := client.SayHello(context.Background(), &simple.Request{Name: "unknown"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, reply.GetMessage())
+
+	again, err := client.SayHello(context.Background(), &simple.Request{Name: "again"})
+	require.NoError(t, err)
+	assert.Equal(t, reply.GetMessage(), again.GetMessage())
+}
+
+func TestNotFound(t *testing.T) {
+	srv := NewFromProto(t, []string{"../example/simple"}, "simple.proto")
+	srv.NotFound("UNIMPLEMENTED", true)
+
+	client := simple.NewGripmockClient(srv.Conn())
+	_, err := client.SayHello(context.Background(), &simple.Request{Name: "unknown"})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+	assert.Equal(t, "can't find stub for simple.Gripmock/SayHello", status.Convert(err).Message())
+	assert.Len(t, status.Convert(err).Details(), 1)
+}
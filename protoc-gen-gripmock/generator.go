@@ -71,18 +71,25 @@ type generatorParam struct {
 }
 
 type Service struct {
-	Name    string
+	Name string
+	// Type is the Go type implementing the service, unique among the generated services
+	Type    string
 	Package string
 	Methods []methodTemplate
 }
 
 type methodTemplate struct {
-	SvcPackage  string
+	SvcPackage string
+	// Name is the Go name of the method and ProtoName its name as declared in the proto
 	Name        string
+	ProtoName   string
 	ServiceName string
-	MethodType  string
-	Input       string
-	Output      string
+	// ServiceType is the Type of the service and ServiceFullName its package.Service name
+	ServiceType     string
+	ServiceFullName string
+	MethodType      string
+	Input           string
+	Output          string
 }
 
 const (
@@ -211,14 +218,30 @@ func getGoPackage(proto *descriptor.FileDescriptorProto) (alias string, goPackag
 
 // change the structure also translate method type
 func extractServices(protos []*descriptor.FileDescriptorProto) []Service {
+	// services of different packages may share a name
+	names := map[string]int{}
+	for _, proto := range protos {
+		for _, svc := range proto.GetService() {
+			names[svc.GetName()]++
+		}
+	}
+
 	svcTmp := []Service{}
 	for _, proto := range protos {
 		for _, svc := range proto.GetService() {
 			var s Service
 			s.Name = svc.GetName()
+			s.Type = svc.GetName()
 			alias, _ := getGoPackage(proto)
 			if alias != "" {
 				s.Package = alias + "."
+				if names[svc.GetName()] > 1 {
+					s.Type = alias + "_" + svc.GetName()
+				}
+			}
+			fullName := svc.GetName()
+			if proto.GetPackage() != "" {
+				fullName = proto.GetPackage() + "." + fullName
 			}
 			methods := make([]methodTemplate, len(svc.Method))
 			for j, method := range svc.Method {
@@ -232,12 +255,15 @@ func extractServices(protos []*descriptor.FileDescriptorProto) []Service {
 				}
 
 				methods[j] = methodTemplate{
-					Name:        strings.Title(*method.Name),
-					SvcPackage:  s.Package,
-					ServiceName: svc.GetName(),
-					Input:       getMessageType(protos, method.GetInputType()),
-					Output:      getMessageType(protos, method.GetOutputType()),
-					MethodType:  tipe,
+					Name:            goCamelCase(method.GetName()),
+					ProtoName:       method.GetName(),
+					SvcPackage:      s.Package,
+					ServiceName:     svc.GetName(),
+					ServiceType:     s.Type,
+					ServiceFullName: fullName,
+					Input:           getMessageType(protos, method.GetInputType()),
+					Output:          getMessageType(protos, method.GetOutputType()),
+					MethodType:      tipe,
 				}
 			}
 			s.Methods = methods
@@ -314,3 +340,42 @@ func isKeyword(word string) bool {
 
 	return false
 }
+
+// goCamelCase is the Go name protoc-gen-go gives to a proto name, e.g. get_user becomes GetUser
+func goCamelCase(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// skip over '.' in ".{{lowercase}}"
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			// a leading '_' becomes 'X', to stay exported
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// skip over '_' in "_{{lowercase}}"
+		case isASCIIDigit(c):
+			b = append(b, c)
+		default:
+			// upper case the first letter of a word, keeping the lower case letters following it
+			if isASCIILower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isASCIILower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
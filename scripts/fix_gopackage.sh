@@ -1,5 +1,7 @@
 #!/bin/bash
 
+# Used by setup_examples.sh, gripmock itself rewrites go_package natively
+
 protos=("$@")
 
 # Set sed -i parameter based on OS
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
-	"log"
-	"net"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 
 	"github.com/tokopedia/gripmock/stub"
@@ -15,35 +21,123 @@ import (
 type serverParam struct {
 	address string
 	port    int64
+	tls     stub.TLSOptions
 }
 
 func main() {
 	grpcParam := serverParam{}
-	flag.StringVar(&grpcParam.address, "grpc-listen", "0.0.0.0", "Address the gRPC server will bind to. Default to localhost, set to 0.0.0.0 to use from another machine")
+	flag.StringVar(&grpcParam.address, "grpc-listen", "0.0.0.0", "Address the gRPC server will bind to. Default to localhost, set to 0.0.0.0 to use from another machine, or unix:///path/to.sock for a unix socket")
 	flag.Int64Var(&grpcParam.port, "grpc-port", 4770, "BindPort of gRPC tcp server")
+	flag.StringVar(&grpcParam.tls.CertFile, "tls-cert", "", "Certificate file to serve gRPC over TLS")
+	flag.StringVar(&grpcParam.tls.KeyFile, "tls-key", "", "Private key file of the TLS certificate")
+	flag.StringVar(&grpcParam.tls.ClientCAFile, "tls-client-ca", "", "CA file to verify client certificates against, enables mutual TLS")
+	flag.StringVar(&grpcParam.tls.GenerateDir, "tls-generate-dir", "", "Directory to write a generated self-signed CA, server and client certificates to, the server then uses the generated certificate")
 
 	stubOptions := stub.Options{}
-	flag.StringVar(&stubOptions.BindAddr, "admin-listen", "0.0.0.0", "Address the admin server will bind to. Default to localhost, set to 0.0.0.0 to use from another machine")
+	flag.StringVar(&stubOptions.BindAddr, "admin-listen", "0.0.0.0", "Address the admin server will bind to. Default to localhost, set to 0.0.0.0 to use from another machine, or unix:///path/to.sock for a unix socket")
 	flag.Int64Var(&stubOptions.BindPort, "admin-port", 4771, "BindPort of stub admin server")
 	flag.StringVar(&stubOptions.StubPath, "stubs", "/stubs", "Path where the stub files are (Optional)")
+	flag.StringVar(&stubOptions.TLS.CertFile, "admin-tls-cert", "", "Certificate file to serve the admin API over TLS")
+	flag.StringVar(&stubOptions.TLS.KeyFile, "admin-tls-key", "", "Private key file of the admin TLS certificate")
+	flag.StringVar(&stubOptions.TLS.ClientCAFile, "admin-tls-client-ca", "", "CA file to verify admin client certificates against, enables mutual TLS")
+	flag.StringVar(&stubOptions.TLS.GenerateDir, "admin-tls-generate-dir", "", "Directory to write a generated self-signed CA, server and client certificates to, the admin API then uses the generated certificate")
+	flag.StringVar(&stubOptions.Token, "admin-token", os.Getenv("GRIPMOCK_ADMIN_TOKEN"), "Bearer token required by the admin API, defaults to $GRIPMOCK_ADMIN_TOKEN")
+	flag.StringVar(&stubOptions.BasicAuth, "admin-basic-auth", os.Getenv("GRIPMOCK_ADMIN_BASIC_AUTH"), "user:password basic auth credentials required by the admin API, defaults to $GRIPMOCK_ADMIN_BASIC_AUTH")
+	flag.BoolVar(&stubOptions.ReadOnly, "admin-read-only", false, "Only expose GET /, GET /requests and GET /metrics on the admin API")
+	flag.StringVar(&stubOptions.Fallback, "fallback", "", "Answer calls no stub matches instead of failing them, with defaults or fake values: a mode for every service, e.g. fake, or comma separated service=mode, e.g. hello.Greeter=fake,Health=defaults")
+	flag.Int64Var(&stubOptions.FallbackSeed, "fallback-seed", 0, "Seed of the fake values of fallback responses, the same seed gives the same responses")
+	flag.StringVar(&stubOptions.NotFound, "not-found", "", "gRPC code, by name or number, of the calls no stub matches, UNKNOWN when not set, or default to answer with the default stub of the method, optionally followed by the code for methods without one, e.g. default:NOT_FOUND: a mode for every service, e.g. NOT_FOUND, or comma separated service=mode, e.g. hello.Greeter=default:NOT_FOUND,UNIMPLEMENTED")
+	flag.BoolVar(&stubOptions.NotFoundShortMessage, "not-found-short-message", false, "Return a short message for calls no stub matches, with the diagnostic in the error details")
+
+	grpcWebOptions := stub.GRPCWebOptions{}
+	flag.StringVar(&grpcWebOptions.BindAddr, "grpc-web-listen", "0.0.0.0", "Address the gRPC-Web server will bind to, or unix:///path/to.sock for a unix socket")
+	flag.Int64Var(&grpcWebOptions.BindPort, "grpc-web-port", 0, "BindPort of the gRPC-Web server, gRPC-Web is disabled when not set")
+	flag.Func("grpc-web-allowed-origins", "Comma separated origins allowed by gRPC-Web CORS, any origin is allowed when not set", func(origins string) error {
+		grpcWebOptions.AllowedOrigins = append(grpcWebOptions.AllowedOrigins, strings.Split(origins, ",")...)
+		return nil
+	})
+
+	connectOptions := stub.ConnectOptions{}
+	flag.StringVar(&connectOptions.BindAddr, "connect-listen", "0.0.0.0", "Address the Connect server will bind to, or unix:///path/to.sock for a unix socket")
+	flag.Int64Var(&connectOptions.BindPort, "connect-port", 0, "BindPort of the Connect protocol and HTTP/JSON transcoding server, disabled when not set")
+
+	logLevel := flag.String("log-level", "info", "Log level, one of debug, info, warn or error")
+	logFormat := flag.String("log-format", "text", "Log format, text or json")
+	drainTimeout := flag.Duration("drain-timeout", 5*time.Second, "How long in-flight calls may take to finish on SIGTERM or SIGINT before they are closed")
+	journalDump := flag.String("journal-dump", "", "File to write the recorded requests to on shutdown, as listed by GET /requests (Optional)")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "OTLP/gRPC endpoint to export traces of the mocked calls to, e.g. http://localhost:4317, tracing is disabled when not set")
 
 	flag.Parse()
+	if err := stub.SetupLogger(*logLevel, *logFormat); err != nil {
+		stub.Fatal("Invalid logging flags", "error", err)
+	}
+
+	flushTraces := func(context.Context) error { return nil }
+	if *otlpEndpoint != "" {
+		shutdown, err := stub.SetupTracing(context.Background(), *otlpEndpoint)
+		if err != nil {
+			stub.Fatal("failed to set up tracing", "error", err)
+		}
+		flushTraces = shutdown
+	}
+
+	opts := stub.ServerOptions()
+	if grpcParam.tls.Enabled() {
+		tlsConfig, err := grpcParam.tls.Config()
+		if err != nil {
+			stub.Fatal("failed to configure TLS", "error", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	s := grpc.NewServer(opts...)
+	register(s)
+
+	reflection.Register(s)
+	stub.RegisterHealth(s)
 
 	// run admin stub server
+	stubOptions.Services = func() []string {
+		services := []string{}
+		for name := range s.GetServiceInfo() {
+			services = append(services, name)
+		}
+		sort.Strings(services)
+		return services
+	}
 	stub.RunStubServer(stubOptions)
 
-	tcpAddress := fmt.Sprintf("%s:%d", grpcParam.address, grpcParam.port)
-	lis, err := net.Listen("tcp", tcpAddress)
+	if grpcWebOptions.Enabled() {
+		grpcWebOptions.TLS = grpcParam.tls
+		stub.RunGRPCWebServer(s, stubOptions.Services, nil, grpcWebOptions)
+	}
+	if connectOptions.Enabled() {
+		connectOptions.TLS = grpcParam.tls
+		stub.RunConnectServer(s, stubOptions.Services, nil, connectOptions)
+	}
+
+	lis, addr, err := stub.Listen(grpcParam.address, grpcParam.port)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		stub.Fatal("failed to listen", "error", err)
 	}
 
-	s := grpc.NewServer()
-	register(s)
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGTERM, syscall.SIGINT)
 
-	reflection.Register(s)
-	fmt.Println("Serving gRPC on tcp://" + tcpAddress)
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	slog.Info("Serving gRPC", "address", addr)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			stub.Fatal("failed to serve", "error", err)
+		}
+	}()
+
+	sig := <-term
+	slog.Info("Stopping gRPC Server", "signal", sig.String())
+	stub.Shutdown(s, *drainTimeout)
+	if *journalDump != "" {
+		if err := stub.DumpJournal(*journalDump); err != nil {
+			slog.Error("Fail to dump journal", "error", err)
+		}
 	}
+	_ = flushTraces(context.Background())
 }
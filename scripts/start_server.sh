@@ -7,6 +7,11 @@ cd "/go/src/grpc"
 echo "Running go mod tidy..."
 go mod tidy
 
-# Run the server.go file
-echo "Running server.go..."
-go run ./ $@
\ No newline at end of file
+# Build the server where gripmock caches it, if it does
+server="${GRIPMOCK_SERVER_BIN:-/go/bin/gripmock-server}"
+mkdir -p "$(dirname "$server")"
+
+# Build and exec the server, so that it receives the signals forwarded by gripmock
+echo "Building server.go..."
+go build -o "$server.tmp" ./ && mv "$server.tmp" "$server" || exit 1
+exec "$server" "$@"
@@ -3,7 +3,8 @@
 # Wait for gripmock to be ready (timeout after 20 seconds)
 timeout=20
 while [ $timeout -gt 0 ]; do
-  if grep -q "Serving gRPC on tcp://" gripmock.log; then
+  # msg="Serving gRPC" address=tcp://... in text logs, "msg":"Serving gRPC","address":"tcp://..." in json ones
+  if grep 'Serving gRPC"' gripmock.log | grep -q "tcp://"; then
     echo "gripmock is ready"
     break
   fi
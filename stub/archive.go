@@ -0,0 +1,179 @@
+package stub
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	archiveFormatTar = "tar"
+	archiveFormatZip = "zip"
+)
+
+// archiveFile is a single stub file inside an exported archive
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+// groupStubFiles splits stubs into one file per service and method,
+// named <service>/<method>.json, which is the layout readStubFromFile understands.
+// Names are path escaped, as patterns may hold slashes and glob characters,
+// the stubs themselves keep their service and method.
+func groupStubFiles(stubs []*Stub) ([]archiveFile, error) {
+	files := []archiveFile{}
+	grouped := map[string][]*Stub{}
+	for _, s := range stubs {
+		name := path.Join(url.PathEscape(s.Service), url.PathEscape(s.Method)+".json")
+		if _, ok := grouped[name]; !ok {
+			files = append(files, archiveFile{name: name})
+		}
+		grouped[name] = append(grouped[name], s)
+	}
+
+	for i := range files {
+		byt, err := json.MarshalIndent(grouped[files[i].name], "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files[i].data = byt
+	}
+
+	return files, nil
+}
+
+func writeStubArchive(w io.Writer, format string, stubs []*Stub) error {
+	files, err := groupStubFiles(stubs)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case archiveFormatTar:
+		tw := tar.NewWriter(w)
+		for _, file := range files {
+			hdr := &tar.Header{
+				Name:    file.name,
+				Mode:    0644,
+				Size:    int64(len(file.data)),
+				ModTime: time.Now(),
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := tw.Write(file.data); err != nil {
+				return err
+			}
+		}
+		return tw.Close()
+	case archiveFormatZip:
+		zw := zip.NewWriter(w)
+		for _, file := range files {
+			fw, err := zw.Create(file.name)
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(file.data); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+// readStubArchive decodes stubs from a tar or zip archive produced by /export,
+// or from a plain stub file when the content is not an archive
+func readStubArchive(byt []byte) ([]*Stub, error) {
+	switch {
+	case isZip(byt):
+		return readZipStubs(byt)
+	case isTar(byt):
+		return readTarStubs(byt)
+	default:
+		return unmarshalStubs(byt)
+	}
+}
+
+func isZip(byt []byte) bool {
+	return bytes.HasPrefix(byt, []byte("PK\x03\x04"))
+}
+
+func isTar(byt []byte) bool {
+	// ustar magic is located at offset 257 of the first header block
+	return len(byt) > 262 && string(byt[257:262]) == "ustar"
+}
+
+func readZipStubs(byt []byte) ([]*Stub, error) {
+	zr, err := zip.NewReader(bytes.NewReader(byt), int64(len(byt)))
+	if err != nil {
+		return nil, err
+	}
+
+	stubs := []*Stub{}
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() || !isStubFile(file.Name) {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", file.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", file.Name, err)
+		}
+
+		s, err := unmarshalStubs(content)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal %s: %w", file.Name, err)
+		}
+		stubs = append(stubs, s...)
+	}
+
+	return stubs, nil
+}
+
+func readTarStubs(byt []byte) ([]*Stub, error) {
+	tr := tar.NewReader(bytes.NewReader(byt))
+	stubs := []*Stub{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return stubs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg || !isStubFile(hdr.Name) {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", hdr.Name, err)
+		}
+
+		s, err := unmarshalStubs(content)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal %s: %w", hdr.Name, err)
+		}
+		stubs = append(stubs, s...)
+	}
+}
+
+func isStubFile(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".json")
+}
@@ -0,0 +1,156 @@
+package stub
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+
+	"connectrpc.com/vanguard"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// ConnectOptions configures the listener serving the Connect protocol
+type ConnectOptions struct {
+	// BindPort of the Connect server, Connect is disabled when it is not set
+	// and BindAddr is not a unix:// address
+	BindPort int64
+	BindAddr string
+	TLS      TLSOptions
+}
+
+// Enabled reports whether the Connect protocol is configured
+func (o ConnectOptions) Enabled() bool {
+	return o.BindPort > 0 || IsUnixAddress(o.BindAddr)
+}
+
+// Schema resolves the descriptors and message types of the served services
+type Schema interface {
+	FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error)
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+// globalSchema resolves the services generated into the binary
+type globalSchema struct{}
+
+// servedSchema resolves the services served by the gRPC server
+var servedSchema Schema = globalSchema{}
+
+func (globalSchema) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+func (globalSchema) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	return protoregistry.GlobalTypes.FindMessageByName(name)
+}
+
+func (globalSchema) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	return protoregistry.GlobalTypes.FindMessageByURL(url)
+}
+
+func (globalSchema) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+func (globalSchema) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// RunConnectServer serves the Connect protocol, unary and streaming with JSON or proto messages,
+// with the handlers of the gRPC server s. Methods annotated with google.api.http options
+// are served as REST endpoints on the same listener. services lists the fully qualified names of the
+// services to serve, resolved with schema. A nil schema resolves the services generated into the binary.
+func RunConnectServer(s *grpc.Server, services func() []string, schema Schema, opt ConnectOptions) {
+	if schema == nil {
+		schema = globalSchema{}
+	}
+	handler := &transcoder{server: s, services: services, schema: schema}
+
+	lis, addr, err := Listen(opt.BindAddr, opt.BindPort)
+	if err != nil {
+		Fatal("failed to listen", "error", err)
+	}
+
+	srv := &http.Server{Handler: handler}
+	if opt.TLS.Enabled() {
+		tlsConfig, err := opt.TLS.Config()
+		if err != nil {
+			Fatal("failed to configure Connect TLS", "error", err)
+		}
+		srv.TLSConfig = tlsConfig
+		if err := http2.ConfigureServer(srv, nil); err != nil {
+			Fatal("failed to configure Connect HTTP/2", "error", err)
+		}
+	} else {
+		// bidirectional streams need HTTP/2, served in cleartext without TLS
+		srv.Handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	slog.Info("Serving Connect", "address", httpURL(addr, srv.TLSConfig != nil))
+	serveHTTP(srv, lis)
+}
+
+// transcoder translates HTTP protocols into gRPC calls on the server.
+// It is rebuilt whenever the served services change, e.g. when protos are uploaded at runtime.
+type transcoder struct {
+	server   *grpc.Server
+	services func() []string
+	schema   Schema
+
+	mx          sync.Mutex
+	descriptors []protoreflect.ServiceDescriptor
+	handler     http.Handler
+}
+
+func (t *transcoder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, err := t.current()
+	if err != nil {
+		responseError(err, w)
+		return
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+func (t *transcoder) current() (http.Handler, error) {
+	descriptors := []protoreflect.ServiceDescriptor{}
+	for _, name := range t.services() {
+		desc, err := t.schema.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			continue
+		}
+		if sd, ok := desc.(protoreflect.ServiceDescriptor); ok {
+			descriptors = append(descriptors, sd)
+		}
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if t.handler != nil && slices.Equal(descriptors, t.descriptors) {
+		return t.handler, nil
+	}
+
+	services := make([]*vanguard.Service, len(descriptors))
+	for i, sd := range descriptors {
+		services[i] = vanguard.NewServiceWithSchema(sd, t.server)
+	}
+	handler, err := vanguard.NewTranscoder(services, vanguard.WithDefaultServiceOptions(
+		vanguard.WithTypeResolver(t.schema),
+		vanguard.WithTargetProtocols(vanguard.ProtocolGRPC),
+		vanguard.WithTargetCodecs(vanguard.CodecProto),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Connect handler: %w", err)
+	}
+
+	t.descriptors = descriptors
+	t.handler = handler
+	return handler, nil
+}
@@ -0,0 +1,98 @@
+package stub
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestTranscoder(t *testing.T) {
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	handler := &transcoder{
+		server:   s,
+		services: func() []string { return []string{"grpc.health.v1.Health"} },
+		schema:   globalSchema{},
+	}
+
+	req := httptest.NewRequest("POST", "/grpc.health.v1.Health/Check", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connect-Protocol-Version", "1")
+	wrt := httptest.NewRecorder()
+	handler.ServeHTTP(wrt, req)
+
+	require.Equal(t, http.StatusOK, wrt.Code, wrt.Body.String())
+	assert.JSONEq(t, `{"status":"SERVING"}`, wrt.Body.String())
+}
+
+func TestTranscoderREST(t *testing.T) {
+	options := &descriptorpb.MethodOptions{}
+	proto.SetExtension(options, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Get{Get: "/v1/items/{id}"},
+	})
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("shop.proto"),
+		Package: proto.String("shop"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Item"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("id"),
+				JsonName: proto.String("id"),
+				Number:   proto.Int32(1),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Shop"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("GetItem"),
+				InputType:  proto.String(".shop.Item"),
+				OutputType: proto.String(".shop.Item"),
+				Options:    options,
+			}},
+		}},
+	}, nil)
+	require.NoError(t, err)
+	files := new(protoregistry.Files)
+	require.NoError(t, files.RegisterFile(fd))
+	types := dynamicpb.NewTypes(files)
+
+	// echoes the request
+	s := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		msg := dynamicpb.NewMessage(fd.Messages().Get(0))
+		if err := stream.RecvMsg(msg); err != nil {
+			return err
+		}
+		return stream.SendMsg(msg)
+	}))
+	handler := &transcoder{
+		server:   s,
+		services: func() []string { return []string{"shop.Shop"} },
+		schema:   schema{files, types},
+	}
+
+	wrt := httptest.NewRecorder()
+	handler.ServeHTTP(wrt, httptest.NewRequest("GET", "/v1/items/7", nil))
+	require.Equal(t, http.StatusOK, wrt.Code, wrt.Body.String())
+	assert.JSONEq(t, `{"id":"7"}`, wrt.Body.String())
+}
+
+type schema struct {
+	*protoregistry.Files
+	*dynamicpb.Types
+}
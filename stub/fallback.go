@@ -0,0 +1,259 @@
+package stub
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Fallback modes, answering calls no stub matches instead of returning an error
+const (
+	// FallbackDefaults answers with the default value of every field
+	FallbackDefaults = "defaults"
+	// FallbackFake answers with fake values picked by the type and name of the fields
+	FallbackFake = "fake"
+)
+
+// fallbackMaxDepth stops filling recursive messages
+const fallbackMaxDepth = 3
+
+var (
+	settingsMx = sync.Mutex{}
+	fallback   = fallbackConfig{}
+)
+
+type fallbackConfig struct {
+	modes serviceModes
+	seed  uint64
+}
+
+// serviceModes is a setting of every service, which some services override
+type serviceModes struct {
+	// all is the mode of every service without a mode of its own
+	all string
+	// services are the modes by service name, fully qualified or not
+	services map[string]string
+}
+
+// parseServiceModes parses either a mode for every service, or a comma separated list of service=mode,
+// the mode without a service applying to the other services
+func parseServiceModes(config string, valid func(mode string) error) (serviceModes, error) {
+	modes := serviceModes{services: map[string]string{}}
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		service, mode, found := strings.Cut(entry, "=")
+		if !found {
+			service, mode = "", entry
+		}
+		if err := valid(mode); err != nil {
+			return serviceModes{}, err
+		}
+		if service == "" {
+			modes.all = mode
+			continue
+		}
+		modes.services[service] = mode
+	}
+	return modes, nil
+}
+
+// of returns the mode of the service, empty when not set
+func (m serviceModes) of(service string) string {
+	if mode, ok := m.services[service]; ok {
+		return mode
+	}
+	if mode, ok := m.services[service[strings.LastIndex(service, ".")+1:]]; ok {
+		return mode
+	}
+	return m.all
+}
+
+// SetFallback enables fallback responses for unmatched calls. The config is either a mode,
+// "defaults" or "fake", for every service, or a comma separated list of service=mode, e.g.
+// "hello.Greeter=fake,Health=defaults". An empty config disables the fallback.
+// Fake values are drawn from the seed and the method, so every call of a method gets the same response.
+func SetFallback(config string, seed int64) error {
+	modes, err := parseServiceModes(config, func(mode string) error {
+		if mode != FallbackDefaults && mode != FallbackFake {
+			return fmt.Errorf("unknown fallback mode %q, use %s or %s", mode, FallbackDefaults, FallbackFake)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	settingsMx.Lock()
+	defer settingsMx.Unlock()
+	fallback = fallbackConfig{modes: modes, seed: uint64(seed)}
+	return nil
+}
+
+// fallbackMode returns the fallback mode of the service, empty when disabled, and the seed of fake values
+func fallbackMode(service string) (string, uint64) {
+	settingsMx.Lock()
+	defer settingsMx.Unlock()
+	return fallback.modes.of(service), fallback.seed
+}
+
+// fallbackResponse fills out with the response of the fallback mode
+func fallbackResponse(mode string, seed uint64, service, method string, out proto.Message) {
+	proto.Reset(out)
+	if mode != FallbackFake {
+		return
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(service + "/" + method))
+	f := faker{rand: rand.New(rand.NewPCG(seed, h.Sum64()))}
+	f.message(out.ProtoReflect(), 0)
+}
+
+type faker struct {
+	rand *rand.Rand
+}
+
+func (f faker) message(m protoreflect.Message, depth int) {
+	switch m.Descriptor().FullName() {
+	case "google.protobuf.Timestamp":
+		m.Set(m.Descriptor().Fields().ByName("seconds"), protoreflect.ValueOfInt64(f.time().Unix()))
+		return
+	case "google.protobuf.Duration":
+		m.Set(m.Descriptor().Fields().ByName("seconds"), protoreflect.ValueOfInt64(f.rand.Int64N(3600)))
+		return
+	case "google.protobuf.Any", "google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue":
+		// their content has to be valid, leave them empty
+		return
+	}
+	if depth > fallbackMaxDepth {
+		return
+	}
+
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		// only the first field of a oneof is set
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() && oneof.Fields().Get(0) != fd {
+			continue
+		}
+
+		switch {
+		case fd.IsMap():
+			entries := m.Mutable(fd).Map()
+			for j := 0; j < 1+f.rand.IntN(2); j++ {
+				key := f.value(fd.MapKey()).MapKey()
+				if fd.MapValue().Kind() == protoreflect.MessageKind {
+					f.message(entries.Mutable(key).Message(), depth+1)
+					continue
+				}
+				entries.Set(key, f.value(fd.MapValue()))
+			}
+		case fd.IsList():
+			list := m.Mutable(fd).List()
+			for j := 0; j < 1+f.rand.IntN(3); j++ {
+				if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+					f.message(list.AppendMutable().Message(), depth+1)
+					continue
+				}
+				list.Append(f.value(fd))
+			}
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			f.message(m.Mutable(fd).Message(), depth+1)
+		default:
+			m.Set(fd, f.value(fd))
+		}
+	}
+}
+
+// value returns a fake scalar value for the field
+func (f faker) value(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(f.rand.IntN(2) == 1)
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		// skip the unspecified zero value when there are others
+		if values.Len() > 1 {
+			return protoreflect.ValueOfEnum(values.Get(1 + f.rand.IntN(values.Len()-1)).Number())
+		}
+		return protoreflect.ValueOfEnum(fd.Default().Enum())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(1 + f.rand.Int32N(1000))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if isTimeField(fd) {
+			return protoreflect.ValueOfInt64(f.time().Unix())
+		}
+		return protoreflect.ValueOfInt64(1 + f.rand.Int64N(1000))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(1 + f.rand.Uint32N(1000))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(1 + f.rand.Uint64N(1000))
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(f.rand.IntN(100000)) / 100)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(float64(f.rand.IntN(100000)) / 100)
+	case protoreflect.BytesKind:
+		byt := make([]byte, 8)
+		for i := range byt {
+			byt[i] = byte(f.rand.UintN(256))
+		}
+		return protoreflect.ValueOfBytes(byt)
+	default:
+		return protoreflect.ValueOfString(f.text(fd))
+	}
+}
+
+var fakeNames = []string{"Alice", "Bob", "Carol", "Dave", "Erin", "Frank", "Grace", "Heidi"}
+
+// text returns a fake string matching the name of the field
+func (f faker) text(fd protoreflect.FieldDescriptor) string {
+	name := strings.ToLower(string(fd.Name()))
+	person := fakeNames[f.rand.IntN(len(fakeNames))]
+	switch {
+	case strings.Contains(name, "email"):
+		return fmt.Sprintf("%s%d@example.com", strings.ToLower(person), f.rand.IntN(100))
+	case name == "id" || strings.HasSuffix(name, "_id") || strings.Contains(name, "uuid"):
+		return f.uuid()
+	case strings.Contains(name, "url") || strings.Contains(name, "uri") || strings.Contains(name, "link"):
+		return fmt.Sprintf("https://example.com/%s/%d", name, f.rand.IntN(1000))
+	case strings.Contains(name, "phone"):
+		return fmt.Sprintf("+1555%07d", f.rand.IntN(10000000))
+	case isTimeField(fd):
+		return f.time().Format(time.RFC3339)
+	case strings.Contains(name, "name"):
+		return person
+	default:
+		return fmt.Sprintf("%s %d", name, f.rand.IntN(1000))
+	}
+}
+
+// time returns a fake time of 2024, fixed rather than relative to now to keep responses deterministic
+func (f faker) time() time.Time {
+	return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(f.rand.Int64N(int64(365 * 24 * time.Hour))))
+}
+
+func (f faker) uuid() string {
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(f.rand.UintN(256))
+	}
+	// version 4, RFC 4122 variant
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func isTimeField(fd protoreflect.FieldDescriptor) bool {
+	name := strings.ToLower(string(fd.Name()))
+	return strings.HasSuffix(name, "_at") || strings.HasSuffix(name, "time") || strings.Contains(name, "timestamp") || strings.Contains(name, "date")
+}
@@ -0,0 +1,109 @@
+package stub
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSetFallback(t *testing.T) {
+	defer SetFallback("", 0)
+
+	require.NoError(t, SetFallback("hello.Greeter=fake, Health=defaults", 1))
+	for service, want := range map[string]string{
+		"hello.Greeter":         FallbackFake,
+		"grpc.health.v1.Health": FallbackDefaults,
+		"user.UserService":      "",
+	} {
+		mode, _ := fallbackMode(service)
+		assert.Equal(t, want, mode, service)
+	}
+
+	require.NoError(t, SetFallback("defaults,hello.Greeter=fake", 1))
+	mode, _ := fallbackMode("user.UserService")
+	assert.Equal(t, FallbackDefaults, mode)
+
+	require.ErrorContains(t, SetFallback("hello.Greeter=random", 1), `unknown fallback mode "random"`)
+}
+
+func TestFallbackResponse(t *testing.T) {
+	clearStorage()
+	defer clearStorage()
+	defer SetFallback("", 0)
+
+	user := userDescriptor(t)
+	require.NoError(t, SetFallback("users.Users=fake", 42))
+
+	// unmatched calls get fake values, the same for every call
+	out := dynamicpb.NewMessage(user)
+	require.NoError(t, FindStub(context.Background(), "users.Users", "GetUser", nil, dynamicpb.NewMessage(user), out))
+	fields := user.Fields()
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), out.Get(fields.ByName("id")).String())
+	assert.Regexp(t, regexp.MustCompile(`^[a-z]+[0-9]+@example\.com$`), out.Get(fields.ByName("email")).String())
+	assert.NotZero(t, out.Get(fields.ByName("created_at")).Message().Get(fields.ByName("created_at").Message().Fields().ByName("seconds")).Int())
+	assert.NotZero(t, out.Get(fields.ByName("tags")).List().Len())
+
+	again := dynamicpb.NewMessage(user)
+	require.NoError(t, FindStub(context.Background(), "users.Users", "GetUser", nil, dynamicpb.NewMessage(user), again))
+	assert.True(t, proto.Equal(out, again))
+
+	// another seed gives other values
+	require.NoError(t, SetFallback("users.Users=fake", 7))
+	other := dynamicpb.NewMessage(user)
+	require.NoError(t, FindStub(context.Background(), "users.Users", "GetUser", nil, dynamicpb.NewMessage(user), other))
+	assert.False(t, proto.Equal(out, other))
+
+	// defaults answer an empty message
+	require.NoError(t, SetFallback("defaults", 0))
+	empty := dynamicpb.NewMessage(user)
+	require.NoError(t, FindStub(context.Background(), "users.Users", "GetUser", nil, dynamicpb.NewMessage(user), empty))
+	assert.Zero(t, proto.Size(empty))
+
+	// services without a fallback still fail
+	require.NoError(t, SetFallback("other.Other=fake", 0))
+	require.Error(t, FindStub(context.Background(), "users.Users", "GetUser", nil, dynamicpb.NewMessage(user), dynamicpb.NewMessage(user)))
+}
+
+func userDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  label.Enum(),
+		}
+	}
+	createdAt := field("created_at", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL)
+	createdAt.TypeName = proto.String(".google.protobuf.Timestamp")
+
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("users.proto"),
+		Package:    proto.String("users"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL),
+				field("email", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL),
+				createdAt,
+				field("tags", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_REPEATED),
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+
+	return fd.Messages().Get(0)
+}
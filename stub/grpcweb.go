@@ -0,0 +1,184 @@
+package stub
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// GRPCWebOptions configures the gRPC-Web listener
+type GRPCWebOptions struct {
+	// BindPort of the gRPC-Web server, gRPC-Web is disabled when it is not set
+	// and BindAddr is not a unix:// address
+	BindPort int64
+	BindAddr string
+	// AllowedOrigins are the origins allowed by CORS, any origin is allowed when empty
+	AllowedOrigins []string
+	TLS            TLSOptions
+}
+
+// Enabled reports whether gRPC-Web is configured
+func (o GRPCWebOptions) Enabled() bool {
+	return o.BindPort > 0 || IsUnixAddress(o.BindAddr)
+}
+
+// RunGRPCWebServer serves gRPC-Web requests, both binary and text encoded, with the handlers
+// of the gRPC server s. services lists the fully qualified names of the services to serve,
+// resolved with schema. A nil schema resolves the services generated into the binary.
+func RunGRPCWebServer(s *grpc.Server, services func() []string, schema Schema, opt GRPCWebOptions) {
+	if schema == nil {
+		schema = globalSchema{}
+	}
+	handler := newGRPCWebHandler(&transcoder{server: s, services: services, schema: schema}, opt.AllowedOrigins)
+
+	lis, addr, err := Listen(opt.BindAddr, opt.BindPort)
+	if err != nil {
+		Fatal("failed to listen", "error", err)
+	}
+
+	srv := &http.Server{Handler: handler}
+	if opt.TLS.Enabled() {
+		tlsConfig, err := opt.TLS.Config()
+		if err != nil {
+			Fatal("failed to configure gRPC-Web TLS", "error", err)
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
+	slog.Info("Serving gRPC-Web", "address", httpURL(addr, srv.TLSConfig != nil))
+	serveHTTP(srv, lis)
+}
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+)
+
+// newGRPCWebHandler answers CORS preflight requests and passes gRPC-Web requests to the transcoder,
+// which serves binary gRPC-Web. Text encoded requests are decoded into binary ones on the way in,
+// and their responses encoded back on the way out.
+func newGRPCWebHandler(transcoder http.Handler, allowedOrigins []string) http.Handler {
+	allowed := func(origin string) bool {
+		return len(allowedOrigins) == 0 || slices.Contains(allowedOrigins, "*") || slices.Contains(allowedOrigins, origin)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && allowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "grpc-status, grpc-message, grpc-status-details-bin")
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if w.Header().Get("Access-Control-Allow-Origin") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
+				w.Header().Set("Access-Control-Max-Age", "600")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		contentType := r.Header.Get("Content-Type")
+		switch {
+		case r.Method != http.MethodPost:
+		case isContentType(contentType, grpcWebTextContentType):
+			body, err := decodeGRPCWebText(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r = r.Clone(r.Context())
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			r.Header.Set("Content-Type", grpcWebContentType+strings.TrimPrefix(contentType, grpcWebTextContentType))
+			transcoder.ServeHTTP(&grpcWebTextWriter{ResponseWriter: w}, r)
+			return
+		case isContentType(contentType, grpcWebContentType):
+			transcoder.ServeHTTP(w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Not a gRPC-Web request")
+	})
+}
+
+// isContentType reports whether contentType is base, or base with a codec suffix such as +proto
+func isContentType(contentType, base string) bool {
+	return contentType == base || strings.HasPrefix(contentType, base+"+")
+}
+
+// decodeGRPCWebText decodes a text encoded body, which may be made of several padded base64 chunks
+func decodeGRPCWebText(body io.Reader) ([]byte, error) {
+	text, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	text = bytes.TrimSpace(text)
+
+	decoded := make([]byte, 0, base64.StdEncoding.DecodedLen(len(text)))
+	for len(text) > 0 {
+		chunk := text
+		// a padded chunk ends the base64 string it belongs to
+		if i := bytes.IndexByte(text, '='); i >= 0 {
+			end := i + 1
+			for end < len(text) && text[end] == '=' {
+				end++
+			}
+			chunk = text[:end]
+		}
+		byt, err := base64.StdEncoding.DecodeString(string(chunk))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gRPC-Web text body: %w", err)
+		}
+		decoded = append(decoded, byt...)
+		text = text[len(chunk):]
+	}
+	return decoded, nil
+}
+
+// grpcWebTextWriter base64 encodes the binary gRPC-Web response, each write on its own
+// so that streamed messages can be decoded as they arrive
+type grpcWebTextWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *grpcWebTextWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		contentType := w.Header().Get("Content-Type")
+		w.Header().Set("Content-Type", grpcWebTextContentType+strings.TrimPrefix(contentType, grpcWebContentType))
+		w.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *grpcWebTextWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if _, err := w.ResponseWriter.Write([]byte(base64.StdEncoding.EncodeToString(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *grpcWebTextWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *grpcWebTextWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,80 @@
+package stub
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGRPCWebHandler(t *testing.T) {
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	handler := newGRPCWebHandler(&transcoder{
+		server:   s,
+		services: func() []string { return []string{"grpc.health.v1.Health"} },
+		schema:   globalSchema{},
+	}, []string{"http://allowed.test"})
+
+	preflight := func(origin string) string {
+		req := httptest.NewRequest("OPTIONS", "/grpc.health.v1.Health/Check", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+		wrt := httptest.NewRecorder()
+		handler.ServeHTTP(wrt, req)
+		return wrt.Header().Get("Access-Control-Allow-Origin")
+	}
+	assert.Equal(t, "http://allowed.test", preflight("http://allowed.test"))
+	assert.Empty(t, preflight("http://other.test"))
+
+	msg, err := proto.Marshal(&healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	frame := append([]byte{0, 0, 0, 0, byte(len(msg))}, msg...)
+
+	for _, contentType := range []string{"application/grpc-web+proto", "application/grpc-web-text"} {
+		t.Run(contentType, func(t *testing.T) {
+			body := frame
+			if contentType == "application/grpc-web-text" {
+				body = []byte(base64.StdEncoding.EncodeToString(frame))
+			}
+			req := httptest.NewRequest("POST", "/grpc.health.v1.Health/Check", bytes.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("X-Grpc-Web", "1")
+			wrt := httptest.NewRecorder()
+			handler.ServeHTTP(wrt, req)
+
+			require.Equal(t, http.StatusOK, wrt.Code)
+			resp := wrt.Body.Bytes()
+			if contentType == "application/grpc-web-text" {
+				// each message and the trailer are encoded separately, padding included
+				resp = nil
+				text := wrt.Body.String()
+				for i := 0; i+4 <= len(text); i += 4 {
+					chunk, err := base64.StdEncoding.DecodeString(text[i : i+4])
+					require.NoError(t, err)
+					resp = append(resp, chunk...)
+				}
+			}
+			// trailer names are case-insensitive
+			assert.Contains(t, strings.ToLower(string(resp)), "grpc-status: 0")
+
+			out := new(healthpb.HealthCheckResponse)
+			require.NoError(t, proto.Unmarshal(resp[5:5+int(resp[4])], out))
+			assert.Equal(t, healthpb.HealthCheckResponse_SERVING, out.GetStatus())
+		})
+	}
+
+	wrt := httptest.NewRecorder()
+	handler.ServeHTTP(wrt, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusNotFound, wrt.Code)
+}
@@ -0,0 +1,164 @@
+package stub
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// isBinaryHeader reports whether the header carries bytes, which gRPC requires to end with -bin
+func isBinaryHeader(key string) bool {
+	return strings.HasSuffix(strings.ToLower(key), "-bin")
+}
+
+// decodeBinary decodes the base64 value of a binary header, padded or not, standard or URL encoded
+func decodeBinary(value string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if byt, err := enc.DecodeString(value); err == nil {
+			return byt, nil
+		}
+	}
+	return nil, fmt.Errorf("value %q is not base64", value)
+}
+
+// normalizeHeader encodes the value of a binary header the way received headers are,
+// so that stubs compare bytes whatever the base64 flavor they use
+func normalizeHeader(key, value string) string {
+	if !isBinaryHeader(key) {
+		return value
+	}
+	byt, err := decodeBinary(value)
+	if err != nil {
+		return value
+	}
+	return base64.StdEncoding.EncodeToString(byt)
+}
+
+// metadataHeaders returns the first value and all the values of every header,
+// values of binary headers being base64 encoded
+func metadataHeaders(md metadata.MD) (map[string]string, map[string][]string) {
+	headers := make(map[string]string, len(md))
+	values := make(map[string][]string, len(md))
+	for key, vals := range md {
+		if len(vals) == 0 {
+			continue
+		}
+
+		encoded := make([]string, len(vals))
+		for i, v := range vals {
+			if isBinaryHeader(key) {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			encoded[i] = v
+		}
+		headers[key] = encoded[0]
+		values[key] = encoded
+	}
+	return headers, values
+}
+
+// headerValues returns all the values of the headers of the request,
+// falling back to the single values when only those were given, e.g. through /find
+func (p *findStubPayload) headerValues() map[string][]string {
+	if p.HeaderValues != nil {
+		return p.HeaderValues
+	}
+
+	values := make(map[string][]string, len(p.Headers))
+	for key, value := range p.Headers {
+		values[key] = []string{value}
+	}
+	return values
+}
+
+// expectedHeaders converts the headers of an input rule for matching
+func expectedHeaders(headers map[string]string) map[string]interface{} {
+	cpy := make(map[string]interface{}, len(headers))
+	for key, value := range headers {
+		cpy[key] = normalizeHeader(key, value)
+	}
+	return cpy
+}
+
+// Header values rules, matching every value of multi-valued headers
+const (
+	// headerValuesAny requires one of the expected values to be sent
+	headerValuesAny = "any"
+	// headerValuesAll requires all of the expected values to be sent, in any order
+	headerValuesAll = "all"
+	// headerValuesOrdered requires exactly the expected values to be sent, in the same order
+	headerValuesOrdered = "ordered"
+)
+
+func headerValuesMatch(rule string, expected, actual map[string][]string) bool {
+	for key, want := range expected {
+		got, ok := actual[key]
+		if !ok {
+			// received metadata keys are lowercase
+			got = actual[strings.ToLower(key)]
+		}
+		normalized := make([]string, len(want))
+		for i, v := range want {
+			normalized[i] = normalizeHeader(key, v)
+		}
+
+		switch rule {
+		case headerValuesAny:
+			ok = slices.ContainsFunc(normalized, func(v string) bool { return slices.Contains(got, v) })
+		case headerValuesAll:
+			ok = !slices.ContainsFunc(normalized, func(v string) bool { return !slices.Contains(got, v) })
+		case headerValuesOrdered:
+			ok = slices.Equal(normalized, got)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func headerValuesData(headers map[string][]string) map[string]interface{} {
+	data := make(map[string]interface{}, len(headers))
+	for key, values := range headers {
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			items[i] = v
+		}
+		data[key] = items
+	}
+	return data
+}
+
+// outputMetadata is the metadata sent along with the response of a stub,
+// values of binary headers are decoded from base64
+func outputMetadata(out *Output) (metadata.MD, error) {
+	md := metadata.MD{}
+	add := func(key, value string) error {
+		if isBinaryHeader(key) {
+			byt, err := decodeBinary(value)
+			if err != nil {
+				return fmt.Errorf("binary header %s: %w", key, err)
+			}
+			value = string(byt)
+		}
+		md.Append(key, value)
+		return nil
+	}
+
+	for key, value := range out.Headers {
+		if err := add(key, value); err != nil {
+			return nil, err
+		}
+	}
+	for key, values := range out.HeaderValues {
+		for _, value := range values {
+			if err := add(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return md, nil
+}
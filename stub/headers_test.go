@@ -0,0 +1,96 @@
+package stub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func Test_headerValues(t *testing.T) {
+	clearStorage()
+	defer clearStorage()
+
+	store := func(name string, headers *InputHeaders) {
+		require.NoError(t, storeStub(&Stub{
+			Service: "auth.Auth",
+			Method:  "Verify",
+			Input:   Input{Equals: map[string]interface{}{"name": name}, Headers: headers},
+			Output:  Output{Data: map[string]interface{}{"name": name}},
+		}))
+	}
+	store("any", &InputHeaders{Any: map[string][]string{"Role": {"admin", "owner"}}})
+	store("all", &InputHeaders{All: map[string][]string{"role": {"admin", "viewer"}}})
+	store("ordered", &InputHeaders{Ordered: map[string][]string{"role": {"viewer", "admin"}}})
+	// binary values compare as bytes, whatever their base64 flavor
+	store("binary", &InputHeaders{Contains: map[string]string{"trace-bin": "-_8"}})
+
+	_, values := metadataHeaders(metadata.Pairs("role", "admin", "role", "viewer", "trace-bin", "\xfb\xff"))
+	require.Equal(t, []string{"admin", "viewer"}, values["role"])
+	require.Equal(t, []string{"+/8="}, values["trace-bin"])
+
+	tests := []struct {
+		name    string
+		headers map[string][]string
+		wantErr bool
+	}{
+		{name: "any", headers: map[string][]string{"role": {"viewer", "owner"}}},
+		{name: "any", headers: map[string][]string{"role": {"viewer"}}, wantErr: true},
+		{name: "all", headers: values},
+		{name: "all", headers: map[string][]string{"role": {"admin"}}, wantErr: true},
+		{name: "ordered", headers: map[string][]string{"role": {"viewer", "admin"}}},
+		{name: "ordered", headers: values, wantErr: true},
+		{name: "binary", headers: values},
+		{name: "binary", headers: map[string][]string{"trace-bin": {"AAA="}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			for key, values := range tt.headers {
+				headers[key] = values[0]
+			}
+			_, err := findStub(&findStubPayload{
+				Service:      "auth.Auth",
+				Method:       "Verify",
+				Data:         map[string]interface{}{"name": tt.name},
+				Headers:      headers,
+				HeaderValues: tt.headers,
+			})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	// without all the values, e.g. through /find, the single values are matched
+	_, err := findStub(&findStubPayload{
+		Service: "auth.Auth",
+		Method:  "Verify",
+		Data:    map[string]interface{}{"name": "any"},
+		Headers: map[string]string{"role": "owner"},
+	})
+	require.NoError(t, err)
+}
+
+func Test_outputMetadata(t *testing.T) {
+	md, err := outputMetadata(&Output{
+		Headers:      map[string]string{"version": "1", "trace-bin": "+/8="},
+		HeaderValues: map[string][]string{"role": {"admin", "viewer"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, metadata.MD{
+		"version":   {"1"},
+		"trace-bin": {"\xfb\xff"},
+		"role":      {"admin", "viewer"},
+	}, md)
+
+	err = validateStub(&Stub{
+		Service: "auth.Auth",
+		Method:  "Verify",
+		Input:   Input{Contains: map[string]interface{}{}},
+		Output:  Output{Data: map[string]interface{}{}, Headers: map[string]string{"trace-bin": "not base64!"}},
+	})
+	require.ErrorContains(t, err, "binary header trace-bin")
+}
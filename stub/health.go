@@ -0,0 +1,119 @@
+package stub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthServer answers grpc.health.v1.Health, its statuses are set through the admin API
+var healthServer = health.NewServer()
+
+// healthStatuses mirrors the statuses set on healthServer, which cannot be listed
+var healthStatuses = struct {
+	mx       sync.Mutex
+	statuses map[string]healthpb.HealthCheckResponse_ServingStatus
+}{statuses: map[string]healthpb.HealthCheckResponse_ServingStatus{"": healthpb.HealthCheckResponse_SERVING}}
+
+// RegisterHealth registers the grpc.health.v1.Health service on s,
+// unless the served protos already declare it
+func RegisterHealth(s *grpc.Server) {
+	if _, ok := s.GetServiceInfo()[healthpb.Health_ServiceDesc.ServiceName]; ok {
+		return
+	}
+	healthpb.RegisterHealthServer(s, healthServer)
+}
+
+// setHealth sets the status of a service, "" being the whole server.
+// Watch streams of the service receive the new status.
+func setHealth(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	healthStatuses.mx.Lock()
+	defer healthStatuses.mx.Unlock()
+
+	healthStatuses.statuses[service] = status
+	healthServer.SetServingStatus(service, status)
+}
+
+// InitHealth reports services served, unless their status was already set
+func InitHealth(services []string) {
+	healthStatuses.mx.Lock()
+	defer healthStatuses.mx.Unlock()
+
+	for _, service := range services {
+		if _, ok := healthStatuses.statuses[service]; ok {
+			continue
+		}
+		healthStatuses.statuses[service] = healthpb.HealthCheckResponse_SERVING
+		healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+}
+
+type healthStatus struct {
+	Service string `json:"service"`
+	Status  string `json:"status"`
+}
+
+func listHealth(w http.ResponseWriter, r *http.Request) {
+	healthStatuses.mx.Lock()
+	statuses := make([]healthStatus, 0, len(healthStatuses.statuses))
+	for service, status := range healthStatuses.statuses {
+		statuses = append(statuses, healthStatus{Service: service, Status: status.String()})
+	}
+	healthStatuses.mx.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Service < statuses[j].Service
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(statuses); err != nil {
+		slog.Error("Error writing listHealth response", "error", err)
+	}
+}
+
+// handleSetHealth accepts a single status or an array of them,
+// e.g. {"service":"package.Service","status":"NOT_SERVING"}
+func handleSetHealth(w http.ResponseWriter, r *http.Request) {
+	byt, err := io.ReadAll(r.Body)
+	if err != nil {
+		responseError(err, w)
+		return
+	}
+
+	var statuses []healthStatus
+	if err := json.Unmarshal(byt, &statuses); err != nil {
+		single := healthStatus{}
+		if err := json.Unmarshal(byt, &single); err != nil {
+			responseError(err, w)
+			return
+		}
+		statuses = []healthStatus{single}
+	}
+
+	parsed := make([]healthpb.HealthCheckResponse_ServingStatus, len(statuses))
+	for i, s := range statuses {
+		status, ok := healthpb.HealthCheckResponse_ServingStatus_value[s.Status]
+		if !ok || status == int32(healthpb.HealthCheckResponse_UNKNOWN) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Invalid status %q of service %q, use SERVING, NOT_SERVING or SERVICE_UNKNOWN", s.Status, s.Service)
+			return
+		}
+		parsed[i] = healthpb.HealthCheckResponse_ServingStatus(status)
+	}
+
+	for i, s := range statuses {
+		setHealth(s.Service, parsed[i])
+	}
+
+	if _, err := w.Write([]byte("Success set health")); err != nil {
+		slog.Error("Error writing handleSetHealth response", "error", err)
+	}
+}
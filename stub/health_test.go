@@ -0,0 +1,74 @@
+package stub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealth(t *testing.T) {
+	s := grpc.NewServer()
+	RegisterHealth(s)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	ctx := context.Background()
+
+	setHealth("health.Service", healthpb.HealthCheckResponse_SERVING)
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "health.Service"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	watch, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "health.Service"})
+	require.NoError(t, err)
+	update, err := watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, update.GetStatus())
+
+	router := newRouter(Options{})
+	wrt := httptest.NewRecorder()
+	router.ServeHTTP(wrt, httptest.NewRequest("POST", "/health", bytes.NewReader([]byte(`{"service":"health.Service","status":"NOT_SERVING"}`))))
+	require.Equal(t, http.StatusOK, wrt.Code, wrt.Body.String())
+
+	// the change is pushed to watchers
+	update, err = watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, update.GetStatus())
+
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "health.Service"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+
+	// a status set explicitly is kept when the service is served again
+	InitHealth([]string{"health.Service"})
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "health.Service"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+
+	wrt = httptest.NewRecorder()
+	router.ServeHTTP(wrt, httptest.NewRequest("GET", "/health", nil))
+	statuses := []healthStatus{}
+	require.NoError(t, json.Unmarshal(wrt.Body.Bytes(), &statuses))
+	assert.Contains(t, statuses, healthStatus{Service: "", Status: "SERVING"})
+	assert.Contains(t, statuses, healthStatus{Service: "health.Service", Status: "NOT_SERVING"})
+
+	wrt = httptest.NewRecorder()
+	router.ServeHTTP(wrt, httptest.NewRequest("POST", "/health", bytes.NewReader([]byte(`{"service":"health.Service","status":"BROKEN"}`))))
+	assert.Equal(t, http.StatusBadRequest, wrt.Code)
+}
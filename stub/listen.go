@@ -0,0 +1,74 @@
+package stub
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+const unixScheme = "unix://"
+
+// IsUnixAddress reports whether address is a unix:// socket address
+func IsUnixAddress(address string) bool {
+	return strings.HasPrefix(address, unixScheme)
+}
+
+// Listen listens on a unix:// socket address, or on the tcp address and port otherwise.
+// The port is ignored for unix sockets. A stale socket file left by a previous run is removed.
+// It returns the listener along with its URL, e.g. tcp://0.0.0.0:4770 or unix:///tmp/gripmock.sock
+func Listen(address string, port int64) (net.Listener, string, error) {
+	if socket, ok := strings.CutPrefix(address, unixScheme); ok {
+		if socket == "" {
+			return nil, "", fmt.Errorf("missing socket path in %s", address)
+		}
+		if err := removeStaleSocket(socket); err != nil {
+			return nil, "", err
+		}
+
+		lis, err := net.Listen("unix", socket)
+		if err != nil {
+			return nil, "", err
+		}
+		return lis, unixScheme + socket, nil
+	}
+
+	tcpAddress := fmt.Sprintf("%s:%d", address, port)
+	lis, err := net.Listen("tcp", tcpAddress)
+	if err != nil {
+		return nil, "", err
+	}
+	return lis, "tcp://" + tcpAddress, nil
+}
+
+// removeStaleSocket removes the socket file at path, refusing to remove any other kind of file
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat socket %s: %w", path, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove stale socket %s: %w", path, err)
+	}
+	return nil
+}
+
+// httpURL shows a tcp:// listener URL with the scheme HTTP is served with
+func httpURL(addr string, tls bool) string {
+	url, ok := strings.CutPrefix(addr, "tcp://")
+	if !ok {
+		return addr
+	}
+	if tls {
+		return "https://" + url
+	}
+	return "http://" + url
+}
@@ -0,0 +1,45 @@
+package stub
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen(t *testing.T) {
+	lis, addr, err := Listen("127.0.0.1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "tcp://127.0.0.1:0", addr)
+	lis.Close()
+
+	socket := filepath.Join(t.TempDir(), "gripmock.sock")
+	// a socket file left by a previous run does not prevent listening
+	stale, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	require.FileExists(t, socket)
+
+	lis, addr, err = Listen("unix://"+socket, 4770)
+	require.NoError(t, err)
+	defer lis.Close()
+	assert.Equal(t, "unix://"+socket, addr)
+
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	conn.Close()
+
+	_, _, err = Listen("unix://", 0)
+	assert.Error(t, err)
+
+	// other files are not removed
+	file := filepath.Join(t.TempDir(), "foo")
+	require.NoError(t, os.WriteFile(file, []byte("keep"), 0644))
+	_, _, err = Listen("unix://"+file, 0)
+	require.ErrorContains(t, err, "is not a socket")
+	require.FileExists(t, file)
+}
@@ -0,0 +1,53 @@
+package stub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// SetupLogger sets the default slog logger, writing to stdout.
+// level is one of debug, info, warn or error, format is text or json.
+func SetupLogger(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q, use debug, info, warn or error", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text", "":
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	default:
+		return fmt.Errorf("invalid log format %q, use text or json", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Fatal logs the error and exits
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// logLookup logs a mocked call along with the stub answering it.
+// The request itself is only logged at debug level.
+func logLookup(ctx context.Context, stub *findStubPayload, match *stubMatch, err error) {
+	attrs := []any{"service", stub.Service, "method", stub.Method}
+	if slog.Default().Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs, "data", stub.Data, "headers", stub.Headers)
+	}
+
+	if err != nil {
+		slog.WarnContext(ctx, "Stub not found", append(attrs, "error", err)...)
+		return
+	}
+	slog.InfoContext(ctx, "Stub matched", append(attrs, "rule", match.Rule, "index", match.Index)...)
+}
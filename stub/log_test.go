@@ -0,0 +1,53 @@
+package stub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupLogger(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	assert.NoError(t, SetupLogger("debug", "json"))
+	assert.NoError(t, SetupLogger("WARN", "text"))
+	assert.Error(t, SetupLogger("verbose", "text"))
+	assert.Error(t, SetupLogger("info", "xml"))
+}
+
+func TestLogLookup(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	buf := &bytes.Buffer{}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	payload := &findStubPayload{Service: "Greeter", Method: "SayHello", Data: map[string]interface{}{"name": "tokopedia"}}
+	logLookup(context.Background(), payload, &stubMatch{Rule: "equals", Index: 1}, nil)
+
+	entry := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "Stub matched", entry["msg"])
+	assert.Equal(t, "Greeter", entry["service"])
+	assert.Equal(t, "SayHello", entry["method"])
+	assert.Equal(t, "equals", entry["rule"])
+	assert.Equal(t, float64(1), entry["index"])
+	// the request is only logged at debug level
+	assert.NotContains(t, entry, "data")
+
+	buf.Reset()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	logLookup(context.Background(), payload, nil, fmt.Errorf("Can't find stub"))
+
+	entry = map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "Stub not found", entry["msg"])
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "Can't find stub", entry["error"])
+	assert.Equal(t, map[string]interface{}{"name": "tokopedia"}, entry["data"])
+}
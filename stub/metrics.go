@@ -0,0 +1,119 @@
+package stub
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// metrics holds the collectors served on /metrics of the admin API
+var metrics = prometheus.NewRegistry()
+
+var (
+	callsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gripmock_calls_total",
+		Help: "gRPC calls received, by service, method and status code.",
+	}, []string{"service", "method", "code"})
+	callDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gripmock_call_duration_seconds",
+		Help:    "Duration of the gRPC calls, by service and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "method"})
+	stubLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gripmock_stub_lookups_total",
+		Help: "Stub lookups, by service, method and whether a stub matched.",
+	}, []string{"service", "method", "result"})
+	injectedLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gripmock_injected_latency_seconds",
+		Help:    "Latency injected by the latency of matched stubs, by service and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "method"})
+)
+
+func init() {
+	metrics.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		callsTotal,
+		callDuration,
+		stubLookups,
+		injectedLatency,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gripmock_stubs",
+			Help: "Stubs currently stored.",
+		}, countStubs),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gripmock_journal_requests",
+			Help: "Distinct requests recorded in the journal.",
+		}, countRequests),
+	)
+}
+
+var metricsHandler = promhttp.HandlerFor(metrics, promhttp.HandlerOpts{})
+
+func countStubs() float64 {
+	mx.Lock()
+	defer mx.Unlock()
+
+	count := 0
+	for _, methods := range stubStorage {
+		for _, stubs := range methods {
+			count += len(stubs)
+		}
+	}
+	return float64(count)
+}
+
+func countRequests() float64 {
+	mx.Lock()
+	defer mx.Unlock()
+
+	return float64(len(requestStorage))
+}
+
+func observeLookup(service, method string, err error) {
+	result := "matched"
+	if err != nil {
+		result = "unmatched"
+	}
+	stubLookups.WithLabelValues(service, method, result).Inc()
+}
+
+// ServerOptions instruments a gRPC server serving stubs with metrics and tracing
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		// spans are only recorded once SetupTracing sets the tracer provider
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			start := time.Now()
+			resp, err := handler(ctx, req)
+			observeCall(info.FullMethod, start, err)
+			return resp, err
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			start := time.Now()
+			err := handler(srv, ss)
+			observeCall(info.FullMethod, start, err)
+			return err
+		}),
+	}
+}
+
+func observeCall(fullMethod string, start time.Time, err error) {
+	service, method := splitMethod(fullMethod)
+	callsTotal.WithLabelValues(service, method, status.Code(err).String()).Inc()
+	callDuration.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+}
+
+// splitMethod splits /package.Service/Method
+func splitMethod(fullMethod string) (string, string) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service, method
+}
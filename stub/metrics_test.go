@@ -0,0 +1,67 @@
+package stub
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetrics(t *testing.T) {
+	clearStorage()
+	require.NoError(t, storeStub(&Stub{
+		Service: "metrics.Service",
+		Method:  "Method",
+		Input:   Input{Equals: map[string]interface{}{"name": "match"}},
+		Output:  Output{Data: map[string]interface{}{}},
+	}))
+
+	matched := stubLookups.WithLabelValues("metrics.Service", "Method", "matched")
+	unmatched := stubLookups.WithLabelValues("metrics.Service", "Method", "unmatched")
+	ok := callsTotal.WithLabelValues("grpc.health.v1.Health", "Check", "OK")
+	notFound := callsTotal.WithLabelValues("grpc.health.v1.Health", "Check", "NotFound")
+	before := []float64{testutil.ToFloat64(matched), testutil.ToFloat64(unmatched), testutil.ToFloat64(ok), testutil.ToFloat64(notFound)}
+
+	_, err := findStub(&findStubPayload{Service: "metrics.Service", Method: "Method", Data: map[string]interface{}{"name": "match"}})
+	require.NoError(t, err)
+	_, err = findStub(&findStubPayload{Service: "metrics.Service", Method: "Method", Data: map[string]interface{}{"name": "other"}})
+	require.Error(t, err)
+
+	s := grpc.NewServer(ServerOptions()...)
+	RegisterHealth(s)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "metrics.Unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	assert.Equal(t, before[0]+1, testutil.ToFloat64(matched))
+	assert.Equal(t, before[1]+1, testutil.ToFloat64(unmatched))
+	assert.Equal(t, before[2]+1, testutil.ToFloat64(ok))
+	assert.Equal(t, before[3]+1, testutil.ToFloat64(notFound))
+
+	// metrics are readable in read-only mode too
+	wrt := httptest.NewRecorder()
+	newRouter(Options{ReadOnly: true}).ServeHTTP(wrt, httptest.NewRequest("GET", "/metrics", nil))
+	body := wrt.Body.String()
+	assert.Contains(t, body, "gripmock_stubs 1\n")
+	assert.Contains(t, body, "gripmock_journal_requests 2\n")
+	assert.Contains(t, body, "gripmock_call_duration_seconds_bucket")
+}
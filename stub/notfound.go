@@ -0,0 +1,104 @@
+package stub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NotFoundDefault answers the calls no stub matches with the default stub of the method
+const NotFoundDefault = "default"
+
+var notFound = notFoundConfig{}
+
+type notFoundConfig struct {
+	modes serviceModes
+	// short moves the diagnostic of the error into its details
+	short bool
+}
+
+// SetNotFound sets how calls no stub matches are answered. The config is either a mode for every service,
+// or a comma separated list of service=mode, e.g. "hello.Greeter=default:NOT_FOUND,UNIMPLEMENTED". A mode is
+// the gRPC code of the error, by name, e.g. NOT_FOUND, or number, e.g. 5, or "default" to answer with the default
+// stub of the method, followed by the code to return when the method has none, e.g. "default:NOT_FOUND".
+// The code is UNKNOWN when not set. A short message leaves the diagnostic, listing the request and
+// the closest stub, to a DebugInfo detail of the error.
+func SetNotFound(config string, shortMessage bool) error {
+	modes, err := parseServiceModes(config, func(mode string) error {
+		_, _, err := parseNotFoundMode(mode)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	settingsMx.Lock()
+	defer settingsMx.Unlock()
+	notFound = notFoundConfig{modes: modes, short: shortMessage}
+	return nil
+}
+
+// passesToDefault reports whether the calls of the service no stub matches go to the default stub
+func passesToDefault(service string) bool {
+	settingsMx.Lock()
+	mode := notFound.modes.of(service)
+	settingsMx.Unlock()
+
+	passthrough, _, _ := parseNotFoundMode(mode)
+	return passthrough
+}
+
+// parseNotFoundMode splits a not found mode into passing calls through to the default stub and the error code
+func parseNotFoundMode(mode string) (bool, codes.Code, error) {
+	if mode == "" {
+		return false, codes.Unknown, nil
+	}
+
+	passthrough := false
+	if rest, ok := strings.CutPrefix(mode, NotFoundDefault); ok && (rest == "" || rest[0] == ':') {
+		passthrough = true
+		mode = strings.TrimPrefix(rest, ":")
+		if mode == "" {
+			return true, codes.Unknown, nil
+		}
+	}
+
+	code, err := parseCode(mode)
+	if err != nil {
+		return false, code, fmt.Errorf("unknown not found mode %q, use a gRPC code, %s or %s:<code>", mode, NotFoundDefault, NotFoundDefault)
+	}
+	return passthrough, code, nil
+}
+
+// parseCode parses a gRPC code by its name, e.g. NOT_FOUND, or its number
+func parseCode(name string) (codes.Code, error) {
+	var code codes.Code
+	if _, err := strconv.ParseUint(name, 10, 32); err != nil {
+		// names are JSON strings, numbers are not
+		name = strconv.Quote(name)
+	}
+	err := code.UnmarshalJSON([]byte(name))
+	return code, err
+}
+
+// notFoundError is the error of a call no stub matches
+func notFoundError(service, method string, err error) error {
+	settingsMx.Lock()
+	mode, short := notFound.modes.of(service), notFound.short
+	settingsMx.Unlock()
+
+	_, code, _ := parseNotFoundMode(mode)
+	if !short {
+		return status.Error(code, err.Error())
+	}
+
+	st := status.New(code, fmt.Sprintf("can't find stub for %s/%s", service, method))
+	if detailed, derr := st.WithDetails(&errdetails.DebugInfo{Detail: err.Error()}); derr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,83 @@
+package stub
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNotFoundError(t *testing.T) {
+	defer SetNotFound("", false)
+
+	diagnostic := errors.New("Can't find stub \n\nService: hello.Greeter \n\nMethod: SayHello")
+
+	// unknown by default, with the whole diagnostic
+	st := status.Convert(notFoundError("hello.Greeter", "SayHello", diagnostic))
+	assert.Equal(t, codes.Unknown, st.Code())
+	assert.Equal(t, diagnostic.Error(), st.Message())
+
+	require.NoError(t, SetNotFound("NOT_FOUND,Greeter=UNIMPLEMENTED", true))
+	st = status.Convert(notFoundError("hello.Greeter", "SayHello", diagnostic))
+	assert.Equal(t, codes.Unimplemented, st.Code())
+	assert.Equal(t, "can't find stub for hello.Greeter/SayHello", st.Message())
+	require.Len(t, st.Details(), 1)
+	assert.Equal(t, diagnostic.Error(), st.Details()[0].(*errdetails.DebugInfo).GetDetail())
+
+	st = status.Convert(notFoundError("user.UserService", "GetUser", diagnostic))
+	assert.Equal(t, codes.NotFound, st.Code())
+
+	// codes by number
+	require.NoError(t, SetNotFound("5", false))
+	st = status.Convert(notFoundError("hello.Greeter", "SayHello", diagnostic))
+	assert.Equal(t, codes.NotFound, st.Code())
+
+	require.ErrorContains(t, SetNotFound("hello.Greeter=MISSING", false), `unknown not found mode "MISSING"`)
+	require.ErrorContains(t, SetNotFound("default:MISSING", false), `unknown not found mode "MISSING"`)
+	require.Error(t, SetNotFound("defaults", false))
+}
+
+func TestNotFoundDefault(t *testing.T) {
+	clearStorage()
+	defer clearStorage()
+	defer SetNotFound("", false)
+
+	require.NoError(t, StoreStub(&Stub{
+		Service: "hello.Greeter",
+		Method:  "SayHello",
+		Input:   Input{Equals: map[string]interface{}{"name": "tokopedia"}},
+		Output:  Output{Data: map[string]interface{}{"message": "Hello Tokopedia"}},
+	}))
+	require.NoError(t, StoreStub(&Stub{
+		Service: "hello.Greeter",
+		Method:  "SayHello",
+		Input:   Input{Default: true},
+		Output:  Output{Data: map[string]interface{}{"message": "Hello stranger"}},
+	}))
+	find := func(name string) (*Output, error) {
+		return findStub(&findStubPayload{Service: "hello.Greeter", Method: "SayHello", Data: map[string]interface{}{"name": name}})
+	}
+
+	// the default stub is only used by services passing calls through
+	_, err := find("unknown")
+	require.Error(t, err)
+
+	require.NoError(t, SetNotFound("hello.Greeter=default", false))
+	out, err := find("unknown")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello stranger", out.Data["message"])
+
+	out, err = find("tokopedia")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Tokopedia", out.Data["message"])
+
+	// methods without a default stub fail with the code of the mode
+	require.NoError(t, SetNotFound("hello.Greeter=default:NOT_FOUND", false))
+	_, err = findStub(&findStubPayload{Service: "hello.Greeter", Method: "SayGoodbye", Data: map[string]interface{}{"name": "unknown"}})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(notFoundError("hello.Greeter", "SayGoodbye", err)))
+}
@@ -2,7 +2,10 @@ package stub
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"time"
 
 	"google.golang.org/grpc"
@@ -11,24 +14,28 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 func FindStub(ctx context.Context, service, method string, headers metadata.MD, in, out proto.Message) error {
 	pyl := struct {
-		Service string            `json:"service"`
-		Method  string            `json:"method"`
-		Data    interface{}       `json:"data"`
-		Headers map[string]string `json:"headers"`
+		Service      string              `json:"service"`
+		Method       string              `json:"method"`
+		Data         interface{}         `json:"data"`
+		Headers      map[string]string   `json:"headers"`
+		HeaderValues map[string][]string `json:"header_values"`
 	}{
 		Service: service,
 		Method:  method,
 		Data:    in,
 	}
+	if msg, ok := in.(*dynamicpb.Message); ok {
+		// dynamic messages have no struct fields for encoding/json to work with
+		pyl.Data = messageData(msg)
+	}
 	if headers != nil {
-		pyl.Headers = make(map[string]string)
-		for header, values := range headers {
-			pyl.Headers[header] = values[0]
-		}
+		pyl.Headers, pyl.HeaderValues = metadataHeaders(headers)
 	}
 
 	byt, err := json.Marshal(pyl)
@@ -41,10 +48,18 @@ func FindStub(ctx context.Context, service, method string, headers metadata.MD,
 		return err
 	}
 
-	respRPC, err := findStub(&stubPyl)
+	match, err := lookupStub(&stubPyl)
+	traceLookup(ctx, match)
+	logLookup(ctx, &stubPyl, match, err)
 	if err != nil {
-		return err
+		if mode, seed := fallbackMode(service); mode != "" {
+			slog.InfoContext(ctx, "Answering with a fallback response", "service", service, "method", method, "mode", mode)
+			fallbackResponse(mode, seed, service, method, out)
+			return nil
+		}
+		return notFoundError(service, method, err)
 	}
+	respRPC := match.Output
 
 	if respRPC.Error != "" || respRPC.Code != nil {
 		if respRPC.Code == nil {
@@ -56,15 +71,112 @@ func FindStub(ctx context.Context, service, method string, headers metadata.MD,
 		}
 	}
 
-	if respRPC.Headers != nil {
-		md := metadata.New(respRPC.Headers)
+	if respRPC.Headers != nil || respRPC.HeaderValues != nil {
+		md, err := outputMetadata(respRPC)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
 		grpc.SetHeader(ctx, md)
 	}
 
 	if respRPC.Latency != nil {
-		time.Sleep(*respRPC.Latency * time.Millisecond)
+		latency := *respRPC.Latency * time.Millisecond
+		injectedLatency.WithLabelValues(service, method).Observe(latency.Seconds())
+		time.Sleep(latency)
 	}
 
 	data, _ := json.Marshal(respRPC.Data)
 	return protojson.Unmarshal(data, out)
 }
+
+// messageData converts a message into the same shape encoding/json produces
+// for the generated Go types, so dynamic messages match stubs the same way
+func messageData(m protoreflect.Message) map[string]interface{} {
+	data := map[string]interface{}{}
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			continue
+		}
+
+		// generated oneof fields are wrapped in a struct named after the oneof
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			data[goCamelCase(string(oneof.Name()))] = map[string]interface{}{
+				goCamelCase(string(fd.Name())): fieldData(fd, m.Get(fd)),
+			}
+			continue
+		}
+
+		data[string(fd.Name())] = fieldData(fd, m.Get(fd))
+	}
+
+	return data
+}
+
+func fieldData(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		items := make([]interface{}, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			items[i] = singularData(fd, list.Get(i))
+		}
+		return items
+	case fd.IsMap():
+		items := map[string]interface{}{}
+		v.Map().Range(func(key protoreflect.MapKey, val protoreflect.Value) bool {
+			items[fmt.Sprint(key.Interface())] = singularData(fd.MapValue(), val)
+			return true
+		})
+		return items
+	default:
+		return singularData(fd, v)
+	}
+}
+
+func singularData(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageData(v.Message())
+	case protoreflect.EnumKind:
+		return int32(v.Enum())
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	default:
+		return v.Interface()
+	}
+}
+
+// goCamelCase returns the Go name protoc-gen-go uses for a proto identifier
+func goCamelCase(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_' && i == 0:
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// skip the underscore, the next letter is capitalized below
+		case isASCIIDigit(c):
+			b = append(b, c)
+		default:
+			if isASCIILower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isASCIILower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,103 @@
+package stub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// httpServers are the HTTP servers started by the Run functions, stopped by Shutdown
+var httpServers = struct {
+	mx      sync.Mutex
+	servers []*http.Server
+}{}
+
+// serveHTTP serves srv on lis in the background until Shutdown
+func serveHTTP(srv *http.Server, lis net.Listener) {
+	httpServers.mx.Lock()
+	httpServers.servers = append(httpServers.servers, srv)
+	httpServers.mx.Unlock()
+
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ServeTLS(lis, "", "")
+		} else {
+			err = srv.Serve(lis)
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			Fatal("failed to serve", "error", err)
+		}
+	}()
+}
+
+// Shutdown stops the HTTP servers started by the Run functions and then the gRPC server s,
+// letting in-flight calls finish for up to drain before closing them.
+func Shutdown(s *grpc.Server, drain time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+
+	httpServers.mx.Lock()
+	servers := httpServers.servers
+	httpServers.servers = nil
+	httpServers.mx.Unlock()
+
+	// gRPC-Web and Connect calls are served by s, which cannot drain them,
+	// so the HTTP servers must be done before s stops gracefully
+	var wg sync.WaitGroup
+	drained := true
+	var mx sync.Mutex
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				mx.Lock()
+				drained = false
+				mx.Unlock()
+				srv.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !drained {
+		slog.Warn("Drain timeout reached, closing in-flight calls", "timeout", drain)
+		s.Stop()
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("Drain timeout reached, closing in-flight calls", "timeout", drain)
+		s.Stop()
+	}
+}
+
+// DumpJournal writes the requests recorded in the journal to path, as listed by GET /requests
+func DumpJournal(path string) error {
+	byt, err := json.MarshalIndent(allRequests(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode journal: %w", err)
+	}
+	if err := os.WriteFile(path, byt, 0644); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package stub
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestShutdown(t *testing.T) {
+	s := grpc.NewServer()
+	RegisterHealth(s)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(lis)
+
+	httpLis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serveHTTP(&http.Server{Handler: newRouter(Options{})}, httpLis)
+	resp, err := http.Get("http://" + httpLis.Addr().String() + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// a watch never finishes by itself, so it is closed once the drain timeout is reached
+	setHealth("shutdown.Service", healthpb.HealthCheckResponse_SERVING)
+	watch, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{Service: "shutdown.Service"})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.NoError(t, err)
+
+	start := time.Now()
+	Shutdown(s, 200*time.Millisecond)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	_, err = watch.Recv()
+	assert.Error(t, err)
+
+	_, err = http.Get("http://" + httpLis.Addr().String() + "/")
+	assert.Error(t, err)
+}
+
+func TestDumpJournal(t *testing.T) {
+	Clear()
+	defer Clear()
+
+	mx.Lock()
+	storeRequest(&findStubPayload{Service: "Journal", Method: "Dump", Data: map[string]interface{}{"name": "tokopedia"}})
+	mx.Unlock()
+
+	path := filepath.Join(t.TempDir(), "journal.json")
+	require.NoError(t, DumpJournal(path))
+
+	byt, err := os.ReadFile(path)
+	require.NoError(t, err)
+	journal := []map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(byt, &journal))
+	require.Len(t, journal, 1)
+	assert.Equal(t, float64(1), journal[0]["count"])
+
+	assert.Error(t, DumpJournal(filepath.Join(t.TempDir(), "missing", "journal.json")))
+}
@@ -3,7 +3,7 @@ package stub
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"reflect"
 	"regexp"
@@ -12,13 +12,13 @@ import (
 	"sync"
 
 	"github.com/lithammer/fuzzysearch/fuzzy"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 var mx = sync.Mutex{}
 
-// below represent map[servicename][methodname][]expectations
+// below represent map[servicename][methodname][]expectations,
+// servicename being the fully qualified package.Service name once the service is served
 type stubMapping map[string]map[string][]storage
 
 type matchFunc func(interface{}, interface{}) bool
@@ -37,10 +37,6 @@ type request struct {
 }
 
 func storeStub(stub *Stub) error {
-	// due to golang implementation
-	// method name must capital
-	stub.Method = cases.Title(language.Und, cases.NoLower).String(stub.Method)
-
 	return stubStorage.storeStub(stub)
 }
 
@@ -58,67 +54,376 @@ func storeRequest(stub *findStubPayload) {
 }
 
 func (sm *stubMapping) storeStub(stub *Stub) error {
+	if err := resolveStub(stub); err != nil {
+		return err
+	}
+
 	mx.Lock()
 	defer mx.Unlock()
+	sm.appendStub(stub)
+	return nil
+}
+
+// resolveStub names the service and method of the stub the way they are stored
+func resolveStub(stub *Stub) error {
+	// patterns are kept as they are, to be matched against the names of the calls
+	if !isPattern(stub.Service) {
+		service, err := resolveService(stub.Service, servedServices())
+		if err != nil {
+			return err
+		}
+		stub.Service = service
+	}
+	if !isPattern(stub.Method) {
+		stub.Method = canonicalMethod(stub.Service, stub.Method)
+	}
+	return nil
+}
 
+// appendStub stores a resolved stub, mx must be held
+func (sm *stubMapping) appendStub(stub *Stub) {
 	strg := storage{
 		Input:  stub.Input,
 		Output: stub.Output,
 	}
+	compilePattern(stub.Service)
+	compilePattern(stub.Method)
 	if (*sm)[stub.Service] == nil {
 		(*sm)[stub.Service] = make(map[string][]storage)
 	}
-	(*sm)[stub.Service][stub.Method] = append((*sm)[stub.Service][stub.Method], strg)
+	method := sm.storedMethod(stub.Service, stub.Method)
+	(*sm)[stub.Service][method] = append((*sm)[stub.Service][method], strg)
+}
+
+// storedMethod returns the name the stubs of method are stored under: the name of the stubs
+// stored first when they differ only by case, so that a method has a single list of stubs
+func (sm stubMapping) storedMethod(service, method string) string {
+	methods := sm[service]
+	if _, ok := methods[method]; ok || isPattern(method) {
+		return method
+	}
+	for name := range methods {
+		if !isPattern(name) && strings.EqualFold(name, method) {
+			return name
+		}
+	}
+	return method
+}
+
+// importStubs validates and resolves all the stubs before storing any of them,
+// so that an invalid stub leaves the stored stubs as they were. replace drops them first.
+func importStubs(stubs []*Stub, replace bool) error {
+	for _, stub := range stubs {
+		if err := validateStub(stub); err != nil {
+			return fmt.Errorf("invalid stub for %s/%s: %w", stub.Service, stub.Method, err)
+		}
+		if err := resolveStub(stub); err != nil {
+			return err
+		}
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	if replace {
+		stubStorage = stubMapping{}
+		regexps = map[string]*regexp.Regexp{}
+	}
+	for _, stub := range stubs {
+		stubStorage.appendStub(stub)
+	}
 	return nil
 }
 
+// servedServices lists the fully qualified names of the served services, if they are known
+func servedServices() []string {
+	if listServices == nil {
+		return nil
+	}
+	return listServices()
+}
+
+// resolveService returns the fully qualified name of the served service named service,
+// which may be its short name. Names of services which are not served are returned as is.
+func resolveService(service string, services []string) (string, error) {
+	if strings.Contains(service, ".") {
+		return service, nil
+	}
+
+	candidates := []string{}
+	for _, served := range services {
+		if served == service {
+			// a service declared without package
+			return service, nil
+		}
+		if strings.HasSuffix(served, "."+service) {
+			candidates = append(candidates, served)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return service, nil
+	case 1:
+		return candidates[0], nil
+	}
+	sort.Strings(candidates)
+	return "", fmt.Errorf("service name %s is ambiguous, use the fully qualified name, one of %s", service, strings.Join(candidates, ", "))
+}
+
+// canonicalMethod returns the name of the method as declared in the proto of service,
+// matched case-insensitively. Methods of services which are not served are returned as is.
+func canonicalMethod(service, method string) string {
+	desc, err := servedSchema.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return method
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return method
+	}
+
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		if name := string(methods.Get(i).Name()); strings.EqualFold(name, method) {
+			return name
+		}
+	}
+	return method
+}
+
+// stubsOf returns the stubs of method, matching its name case-insensitively
+// when no stub was stored with the exact name. Stubs whose names differ only
+// by case are stored together, so at most one list matches.
+func stubsOf(methods map[string][]storage, method string) ([]storage, bool) {
+	if stubs, ok := methods[method]; ok {
+		return stubs, true
+	}
+
+	for name, stubs := range methods {
+		if strings.EqualFold(name, method) {
+			return stubs, true
+		}
+	}
+	return nil, false
+}
+
+// stubsFor returns the stubs of the method of service, followed by the stubs
+// whose service or method name is a pattern matching them
+func (sm stubMapping) stubsFor(service, method string, services []string) ([]storage, error) {
+	stubs := []storage{}
+	methods, err := sm.methodsOf(service, services)
+	if err == nil {
+		exact, ok := stubsOf(methods, method)
+		if !ok {
+			err = fmt.Errorf("can't find stub for Service:%s and Method:%s", service, method)
+		}
+		stubs = append(stubs, exact...)
+	}
+	stubs = append(stubs, sm.wildcardStubs(service, method)...)
+
+	if len(stubs) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Stub for Service:%s and Method:%s is empty", service, method)
+	}
+	return stubs, nil
+}
+
+// methodsOf returns the stubs of service, stored under its fully qualified name or
+// under its short name, e.g. by stub files loaded before the service was served
+func (sm stubMapping) methodsOf(service string, services []string) (map[string][]storage, error) {
+	if methods, ok := sm[service]; ok {
+		return methods, nil
+	}
+
+	resolved, err := resolveService(service, services)
+	if err != nil {
+		return nil, err
+	}
+	if methods, ok := sm[resolved]; ok {
+		return methods, nil
+	}
+
+	if i := strings.LastIndex(service, "."); i >= 0 {
+		short := service[i+1:]
+		if _, err := resolveService(short, services); err == nil {
+			if methods, ok := sm[short]; ok {
+				return methods, nil
+			}
+		}
+	} else {
+		// the served services may not be known, e.g. when looking up through the admin API
+		stored := []string{}
+		for name := range sm {
+			if strings.HasSuffix(name, "."+service) && !isPattern(name) {
+				stored = append(stored, name)
+			}
+		}
+		if len(stored) == 1 {
+			return sm[stored[0]], nil
+		}
+	}
+
+	return nil, fmt.Errorf("can't find stub for Service: %s", service)
+}
+
 func allStub() stubMapping {
 	mx.Lock()
 	defer mx.Unlock()
 	return stubStorage
 }
 
+// exportStubs flattens the stub mapping back into the stub file format,
+// sorted by service and method while keeping the matching order of each method
+func exportStubs() []*Stub {
+	mx.Lock()
+	defer mx.Unlock()
+
+	services := make([]string, 0, len(stubStorage))
+	for service := range stubStorage {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	stubs := []*Stub{}
+	for _, service := range services {
+		methods := make([]string, 0, len(stubStorage[service]))
+		for method := range stubStorage[service] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			for _, strg := range stubStorage[service][method] {
+				stubs = append(stubs, &Stub{
+					Service: service,
+					Method:  method,
+					Input:   strg.Input,
+					Output:  strg.Output,
+				})
+			}
+		}
+	}
+
+	return stubs
+}
+
 func allRequests() []*request {
 	mx.Lock()
 	defer mx.Unlock()
 	return requestStorage
 }
 
+// Request is a call recorded by the mock server,
+// the same entry served by the /requests endpoint
+type Request struct {
+	Service string
+	Method  string
+	Data    map[string]interface{}
+	Headers map[string]string
+	// HeaderValues are all the values of the headers, Headers only holding the first one
+	HeaderValues map[string][]string
+	Count        int
+}
+
+// StoreStub validates and stores a stub, the same way the /add endpoint does
+func StoreStub(stub *Stub) error {
+	if err := validateStub(stub); err != nil {
+		return err
+	}
+
+	return storeStub(stub)
+}
+
+// SetServices sets the function listing the fully qualified names of the served services,
+// which resolves the short service names of stubs
+func SetServices(services func() []string) {
+	listServices = services
+}
+
+// SetSchema sets the descriptors of the served services, which resolve the proto names
+// of the methods of stubs. A nil schema resolves the services generated into the binary.
+func SetSchema(schema Schema) {
+	if schema == nil {
+		schema = globalSchema{}
+	}
+	servedSchema = schema
+}
+
+// Requests returns a copy of the recorded calls
+func Requests() []Request {
+	mx.Lock()
+	defer mx.Unlock()
+
+	requests := make([]Request, len(requestStorage))
+	for i, r := range requestStorage {
+		requests[i] = Request{
+			Service:      r.Record.Service,
+			Method:       r.Record.Method,
+			Data:         r.Record.Data,
+			Headers:      r.Record.Headers,
+			HeaderValues: r.Record.HeaderValues,
+			Count:        r.Count,
+		}
+	}
+	return requests
+}
+
+// Clear removes all stubs and recorded calls
+func Clear() {
+	clearStorage()
+}
+
 type closeMatch struct {
 	rule        string
 	expect      map[string]interface{}
 	headersRule string
-	headers     map[string]string
+	headers     map[string]interface{}
+}
+
+// stubMatch is the stub answering a request
+type stubMatch struct {
+	Output *Output
+	// Rule is the input rule that matched: equals, equals_unordered, contains, matches or any,
+	// or default when no stub matched and the call was passed to the default stub
+	Rule string
+	// Index is the position of the stub among the stubs of the method
+	Index int
 }
 
 func findStub(stub *findStubPayload) (*Output, error) {
-	// due to golang implementation
-	// method name must capital
-	stub.Method = cases.Title(language.Und, cases.NoLower).String(stub.Method)
+	match, err := lookupStub(stub)
+	if err != nil {
+		return nil, err
+	}
+	return match.Output, nil
+}
+
+func lookupStub(stub *findStubPayload) (*stubMatch, error) {
+	match, err := matchStub(stub)
+	observeLookup(stub.Service, stub.Method, err)
+	return match, err
+}
+
+func matchStub(stub *findStubPayload) (*stubMatch, error) {
+	services := servedServices()
 
 	mx.Lock()
 	defer mx.Unlock()
 	storeRequest(stub)
-	if _, ok := stubStorage[stub.Service]; !ok {
-		return nil, fmt.Errorf("can't find stub for Service: %s", stub.Service)
-	}
-
-	if _, ok := stubStorage[stub.Service][stub.Method]; !ok {
-		return nil, fmt.Errorf("can't find stub for Service:%s and Method:%s", stub.Service, stub.Method)
-	}
-
-	stubs := stubStorage[stub.Service][stub.Method]
-	if len(stubs) == 0 {
-		return nil, fmt.Errorf("Stub for Service:%s and Method:%s is empty", stub.Service, stub.Method)
+	stubs, err := stubStorage.stubsFor(stub.Service, stub.Method, services)
+	if err != nil {
+		return nil, err
 	}
 
 	closestMatch := []closeMatch{}
-	for _, stubrange := range stubs {
+	for i, stubrange := range stubs {
 		if expect := stubrange.Input.Equals; expect != nil {
 			cm := closeMatch{rule: "equals", expect: expect}
 			if equals(stub.Data, expect) {
 				if headersConstraintsApplied(stubrange.Input, stub, &cm) {
-					return &stubrange.Output, nil
+					return &stubMatch{Output: &stubrange.Output, Rule: "equals", Index: i}, nil
 				}
 			}
 			closestMatch = append(closestMatch, cm)
@@ -128,7 +433,7 @@ func findStub(stub *findStubPayload) (*Output, error) {
 			cm := closeMatch{rule: "equals_unordered", expect: expect}
 			if equalsUnordered(stub.Data, expect) {
 				if headersConstraintsApplied(stubrange.Input, stub, &cm) {
-					return &stubrange.Output, nil
+					return &stubMatch{Output: &stubrange.Output, Rule: "equals_unordered", Index: i}, nil
 				}
 			}
 			closestMatch = append(closestMatch, cm)
@@ -138,7 +443,7 @@ func findStub(stub *findStubPayload) (*Output, error) {
 			cm := closeMatch{rule: "contains", expect: expect}
 			if contains(expect, stub.Data) {
 				if headersConstraintsApplied(stubrange.Input, stub, &cm) {
-					return &stubrange.Output, nil
+					return &stubMatch{Output: &stubrange.Output, Rule: "contains", Index: i}, nil
 				}
 			}
 			closestMatch = append(closestMatch, cm)
@@ -148,11 +453,32 @@ func findStub(stub *findStubPayload) (*Output, error) {
 			cm := closeMatch{rule: "matches", expect: expect}
 			if matches(expect, stub.Data) {
 				if headersConstraintsApplied(stubrange.Input, stub, &cm) {
-					return &stubrange.Output, nil
+					return &stubMatch{Output: &stubrange.Output, Rule: "matches", Index: i}, nil
 				}
 			}
 			closestMatch = append(closestMatch, cm)
 		}
+
+	}
+
+	// catch-all stubs only answer the calls no other rule matches, wherever they are stored
+	for i, stubrange := range stubs {
+		if !stubrange.Input.Any {
+			continue
+		}
+		cm := closeMatch{rule: "any", expect: map[string]interface{}{}}
+		if headersConstraintsApplied(stubrange.Input, stub, &cm) {
+			return &stubMatch{Output: &stubrange.Output, Rule: "any", Index: i}, nil
+		}
+		closestMatch = append(closestMatch, cm)
+	}
+
+	if passesToDefault(stub.Service) {
+		for i, stubrange := range stubs {
+			if stubrange.Input.Default {
+				return &stubMatch{Output: &stubrange.Output, Rule: NotFoundDefault, Index: i}, nil
+			}
+		}
 	}
 
 	return nil, stubNotFoundError(stub, closestMatch)
@@ -168,7 +494,8 @@ func copyHeaders(headers map[string]string) map[string]interface{} {
 }
 
 // headersConstraintsApplied checks if the provided headers in the stub match the expected header constraints.
-// It supports three types of header matching: exact equality, containment, and regex pattern matching.
+// It supports exact equality, containment and regex pattern matching on the first value of each header,
+// and any, all or ordered matching on all of their values.
 //
 // Parameters:
 //   - expectedInput: Input containing the header constraints to check against
@@ -182,10 +509,11 @@ func copyHeaders(headers map[string]string) map[string]interface{} {
 //     2. Headers match exactly (Equals)
 //     3. Headers contain all expected values (Contains)
 //     4. Headers match the regex patterns (Matches)
+//     5. One, all or exactly the expected values of each header are sent (Any, All, Ordered)
 //     Returns false if none of the header constraints are satisfied.
 //
 // The function updates the closestMatch (if provided) with:
-//   - headersRule: The type of rule applied ("equal", "contains", or "match")
+//   - headersRule: The type of rule applied ("equal", "contains", "match", "any", "all" or "ordered")
 //   - headers: The expected headers that were checked against
 //
 // Example:
@@ -204,10 +532,10 @@ func headersConstraintsApplied(expectedInput Input, stub *findStubPayload, close
 	headersCopy := copyHeaders(stub.Headers)
 
 	if expected := expectedInput.Headers.Equals; expected != nil {
-		expectedCopy := copyHeaders(expected)
+		expectedCopy := expectedHeaders(expected)
 		if closestMatch != nil {
 			closestMatch.headersRule = "equal"
-			closestMatch.headers = expected
+			closestMatch.headers = expectedCopy
 		}
 		if equals(expectedCopy, headersCopy) {
 			return true
@@ -215,10 +543,10 @@ func headersConstraintsApplied(expectedInput Input, stub *findStubPayload, close
 	}
 
 	if expected := expectedInput.Headers.EqualsUnordered; expected != nil {
-		expectedCopy := copyHeaders(expected)
+		expectedCopy := expectedHeaders(expected)
 		if closestMatch != nil {
 			closestMatch.headersRule = "equal_unordered"
-			closestMatch.headers = expected
+			closestMatch.headers = expectedCopy
 		}
 		if equalsUnordered(expectedCopy, headersCopy) {
 			return true
@@ -226,10 +554,10 @@ func headersConstraintsApplied(expectedInput Input, stub *findStubPayload, close
 	}
 
 	if expected := expectedInput.Headers.Contains; expected != nil {
-		expectedCopy := copyHeaders(expected)
+		expectedCopy := expectedHeaders(expected)
 		if closestMatch != nil {
 			closestMatch.headersRule = "contains"
-			closestMatch.headers = expected
+			closestMatch.headers = expectedCopy
 		}
 		if headerFind(expectedCopy, headersCopy) {
 			return true
@@ -240,13 +568,33 @@ func headersConstraintsApplied(expectedInput Input, stub *findStubPayload, close
 		expectedCopy := copyHeaders(expected)
 		if closestMatch != nil {
 			closestMatch.headersRule = "match"
-			closestMatch.headers = expected
+			closestMatch.headers = expectedCopy
 		}
 		if matches(expectedCopy, headersCopy) {
 			return true
 		}
 	}
 
+	for _, values := range []struct {
+		rule     string
+		expected map[string][]string
+	}{
+		{headerValuesAny, expectedInput.Headers.Any},
+		{headerValuesAll, expectedInput.Headers.All},
+		{headerValuesOrdered, expectedInput.Headers.Ordered},
+	} {
+		if values.expected == nil {
+			continue
+		}
+		if closestMatch != nil {
+			closestMatch.headersRule = values.rule
+			closestMatch.headers = headerValuesData(values.expected)
+		}
+		if headerValuesMatch(values.rule, values.expected, stub.headerValues()) {
+			return true
+		}
+	}
+
 	return false
 }
 
@@ -288,8 +636,7 @@ func stubNotFoundError(stub *findStubPayload, closestMatches []closeMatch) error
 	closestMatchString := renderFieldAsString(closestMatch.expect)
 	template += fmt.Sprintf("\n\nClosest Match \n\n%s:%s", closestMatch.rule, closestMatchString)
 	if closestMatch.headers != nil {
-		headers := copyHeaders(closestMatch.headers)
-		template += "\nHeaders " + closestMatch.headersRule + ":\n" + renderFieldAsString(headers)
+		template += "\nHeaders " + closestMatch.headersRule + ":\n" + renderFieldAsString(closestMatch.headers)
 	}
 
 	return fmt.Errorf(template)
@@ -337,7 +684,7 @@ func regexMatch(expect, actual interface{}) bool {
 	if expectedStringOk && actualStringOk {
 		match, err := regexp.Match(expectedStr, []byte(actualStr))
 		if err != nil {
-			log.Printf("Error on matching regex %s with %s error:%v\n", expect, actual, err)
+			slog.Warn("Error on matching regex", "regex", expect, "value", actual, "error", err)
 		}
 		return match
 	}
@@ -471,6 +818,7 @@ func clearStorage() {
 	defer mx.Unlock()
 
 	stubStorage = stubMapping{}
+	regexps = map[string]*regexp.Regexp{}
 	requestStorage = []*request{}
 }
 
@@ -481,7 +829,7 @@ func readStubFromFile(path string) int {
 func (sm *stubMapping) readStubFromFile(path string) int {
 	files, err := os.ReadDir(path)
 	if err != nil {
-		log.Printf("Can't read stub from %s. %v\n", path, err)
+		slog.Error("Can't read stub", "path", path, "error", err)
 		return 0
 	}
 
@@ -493,51 +841,53 @@ func (sm *stubMapping) readStubFromFile(path string) int {
 		}
 
 		// Only process .json files
-		if !strings.HasSuffix(strings.ToLower(file.Name()), ".json") {
+		if !isStubFile(file.Name()) {
 			continue
 		}
 
 		filePath := path + "/" + file.Name()
 		byt, err := os.ReadFile(filePath)
 		if err != nil {
-			log.Printf("Error when reading file %s. %v. skipping...", file.Name(), err)
+			slog.Warn("Error when reading file, skipping", "file", file.Name(), "error", err)
 			continue
 		}
 
-		// Try to unmarshal as array first
-		var stubs []*Stub
-		err = json.Unmarshal(byt, &stubs)
-		if err == nil && len(stubs) > 0 {
-			// Successfully unmarshaled as array
-			log.Printf("Successfully unmarshaled %s as array with %d stubs", file.Name(), len(stubs))
-			for _, s := range stubs {
-				if err = sm.storeStub(s); err != nil {
-					log.Printf("Error when storing Stub from %s. %v. skipping...", file.Name(), err)
-				} else {
-					count++
-				}
-			}
-			continue
-		}
-
-		// If array unmarshal failed, try as single stub
-		var stub Stub
-		err = json.Unmarshal(byt, &stub)
+		stubs, err := unmarshalStubs(byt)
 		if err != nil {
-			log.Printf("Error when unmarshalling file %s. %v. skipping...", file.Name(), err)
+			slog.Warn("Error when unmarshalling file, skipping", "file", file.Name(), "error", err)
 			continue
 		}
 
-		if err = sm.storeStub(&stub); err != nil {
-			log.Printf("Error when storing Stub from %s. %v. skipping...", file.Name(), err)
-		} else {
-			count++
+		for _, s := range stubs {
+			if err = sm.storeStub(s); err != nil {
+				slog.Warn("Error when storing stub, skipping", "file", file.Name(), "error", err)
+			} else {
+				count++
+			}
 		}
 	}
 
 	return count
 }
 
+// unmarshalStubs decodes the content of a stub file,
+// which is either a single stub or an array of stubs
+func unmarshalStubs(byt []byte) ([]*Stub, error) {
+	// Try to unmarshal as array first
+	var stubs []*Stub
+	if err := json.Unmarshal(byt, &stubs); err == nil && len(stubs) > 0 {
+		return stubs, nil
+	}
+
+	// If array unmarshal failed, try as single stub
+	stub := new(Stub)
+	if err := json.Unmarshal(byt, stub); err != nil {
+		return nil, err
+	}
+
+	return []*Stub{stub}, nil
+}
+
 func headerFind(expect, actual map[string]interface{}) bool {
 	return find(expect, actual, true, false, func(expect, actual interface{}) bool {
 		expectStr, expectOk := expect.(string)
@@ -537,3 +537,88 @@ func Test_readStubFromFile(t *testing.T) {
 		})
 	}
 }
+
+func Test_fullyQualifiedServices(t *testing.T) {
+	clearStorage()
+	defer clearStorage()
+	SetServices(func() []string {
+		return []string{"foo.v1.UserService", "bar.v2.UserService", "simple.Gripmock", "Unpackaged"}
+	})
+	defer SetServices(nil)
+
+	for _, service := range []string{"foo.v1.UserService", "bar.v2.UserService"} {
+		require.NoError(t, storeStub(&Stub{
+			Service: service,
+			Method:  "GetUser",
+			Input:   Input{Contains: map[string]interface{}{}},
+			Output:  Output{Data: map[string]interface{}{"service": service}},
+		}))
+	}
+
+	// identically named services of different packages have their own stubs
+	for _, service := range []string{"foo.v1.UserService", "bar.v2.UserService"} {
+		got, err := findStub(&findStubPayload{Service: service, Method: "GetUser", Data: map[string]interface{}{}})
+		require.NoError(t, err)
+		require.Equal(t, service, got.Data["service"])
+	}
+
+	err := storeStub(&Stub{Service: "UserService", Method: "GetUser"})
+	require.EqualError(t, err, "service name UserService is ambiguous, use the fully qualified name, one of bar.v2.UserService, foo.v1.UserService")
+	_, err = findStub(&findStubPayload{Service: "UserService", Method: "GetUser"})
+	require.Error(t, err)
+
+	// an unambiguous short name is stored under the fully qualified name
+	stub := &Stub{Service: "Gripmock", Method: "SayHello", Input: Input{Contains: map[string]interface{}{}}}
+	require.NoError(t, storeStub(stub))
+	require.Equal(t, "simple.Gripmock", stub.Service)
+	_, err = findStub(&findStubPayload{Service: "simple.Gripmock", Method: "SayHello", Data: map[string]interface{}{}})
+	require.NoError(t, err)
+	_, err = findStub(&findStubPayload{Service: "Gripmock", Method: "SayHello", Data: map[string]interface{}{}})
+	require.NoError(t, err)
+
+	stub = &Stub{Service: "Unpackaged", Method: "Get", Input: Input{Contains: map[string]interface{}{}}}
+	require.NoError(t, storeStub(stub))
+	require.Equal(t, "Unpackaged", stub.Service)
+
+	// stubs stored under a short name before the service was served still match
+	SetServices(nil)
+	require.NoError(t, storeStub(&Stub{Service: "Greeter", Method: "SayHello", Input: Input{Contains: map[string]interface{}{}}}))
+	SetServices(func() []string { return []string{"hello.Greeter"} })
+	_, err = findStub(&findStubPayload{Service: "hello.Greeter", Method: "SayHello", Data: map[string]interface{}{}})
+	require.NoError(t, err)
+}
+
+func Test_protoMethodNames(t *testing.T) {
+	clearStorage()
+	defer clearStorage()
+
+	// the method name of the proto is shown, whatever the case of the stub
+	stub := &Stub{Service: "grpc.health.v1.Health", Method: "check", Input: Input{Contains: map[string]interface{}{}}}
+	require.NoError(t, storeStub(stub))
+	require.Equal(t, "Check", stub.Method)
+	require.Contains(t, allStub()["grpc.health.v1.Health"], "Check")
+
+	_, err := findStub(&findStubPayload{Service: "grpc.health.v1.Health", Method: "Check", Data: map[string]interface{}{}})
+	require.NoError(t, err)
+
+	// methods of services which are not served are matched case-insensitively
+	require.NoError(t, storeStub(&Stub{Service: "user.UserService", Method: "get_user", Input: Input{Contains: map[string]interface{}{}}}))
+	require.Contains(t, allStub()["user.UserService"], "get_user")
+	_, err = findStub(&findStubPayload{Service: "user.UserService", Method: "get_user", Data: map[string]interface{}{}})
+	require.NoError(t, err)
+	_, err = findStub(&findStubPayload{Service: "user.UserService", Method: "Get_User", Data: map[string]interface{}{}})
+	require.NoError(t, err)
+	_, err = findStub(&findStubPayload{Service: "user.UserService", Method: "GetUser", Data: map[string]interface{}{}})
+	require.Error(t, err)
+
+	// stubs whose method names differ only by case are stored together, in the order they were stored
+	require.NoError(t, storeStub(&Stub{Service: "user.UserService", Method: "getUser", Input: Input{Equals: map[string]interface{}{"id": 1}}, Output: Output{Data: map[string]interface{}{"name": "John"}}}))
+	require.NoError(t, storeStub(&Stub{Service: "user.UserService", Method: "GetUser", Input: Input{Equals: map[string]interface{}{"id": 2}}, Output: Output{Data: map[string]interface{}{"name": "Jane"}}}))
+	require.Len(t, allStub()["user.UserService"]["getUser"], 2)
+	require.NotContains(t, allStub()["user.UserService"], "GetUser")
+	for i := 0; i < 10; i++ {
+		out, err := findStub(&findStubPayload{Service: "user.UserService", Method: "GETUSER", Data: map[string]interface{}{"id": 2}})
+		require.NoError(t, err)
+		require.Equal(t, "Jane", out.Data["name"])
+	}
+}
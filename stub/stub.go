@@ -1,11 +1,13 @@
 package stub
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -16,42 +18,147 @@ type Options struct {
 	BindPort int64
 	BindAddr string
 	StubPath string
+
+	// Services lists the services served by the gRPC server
+	Services func() []string
+	// Schema resolves the descriptors of the served services,
+	// the services generated into the binary when nil
+	Schema Schema
+	// Protos registers proto definitions into the running gRPC server,
+	// it is only available in dynamic mode
+	Protos ProtoLoader
+
+	TLS TLSOptions
+	// Token requires requests to carry an "Authorization: Bearer <token>" header
+	Token string
+	// BasicAuth requires requests to carry these "user:password" basic auth credentials
+	BasicAuth string
+	// ReadOnly only exposes listing stubs, recorded requests and metrics
+	ReadOnly bool
+
+	// Fallback answers the calls no stub matches, see SetFallback
+	Fallback string
+	// FallbackSeed draws the fake values of the fallback responses
+	FallbackSeed int64
+	// NotFound is the error code of the calls no stub matches, see SetNotFound
+	NotFound string
+	// NotFoundShortMessage leaves the diagnostic of such errors to their details
+	NotFoundShortMessage bool
+}
+
+// ProtoLoader registers proto definitions at runtime and
+// returns the services they declare
+type ProtoLoader interface {
+	RegisterProtos(sources map[string]string) ([]string, error)
+	RegisterDescriptorSet(set []byte) ([]string, error)
 }
 
 const DEFAULT_PORT = 4771
 
 var stubPath string
+var listServices func() []string
+var protoLoader ProtoLoader
 
 func RunStubServer(opt Options) {
 	if opt.BindPort <= 0 {
 		opt.BindPort = DEFAULT_PORT
 	}
 	stubPath = opt.StubPath
-	addr := fmt.Sprintf("%s:%d", opt.BindAddr, opt.BindPort)
+	listServices = opt.Services
+	SetSchema(opt.Schema)
+	protoLoader = opt.Protos
+	if err := SetFallback(opt.Fallback, opt.FallbackSeed); err != nil {
+		Fatal("Invalid fallback", "error", err)
+	}
+	if err := SetNotFound(opt.NotFound, opt.NotFoundShortMessage); err != nil {
+		Fatal("Invalid not found mode", "error", err)
+	}
+	r := newRouter(opt)
+
+	if opt.StubPath != "" {
+		count := readStubFromFile(opt.StubPath)
+		slog.Info("Loaded stubs", "count", count, "path", opt.StubPath)
+	}
+
+	if listServices != nil {
+		InitHealth(listServices())
+	}
+
+	lis, addr, err := Listen(opt.BindAddr, opt.BindPort)
+	if err != nil {
+		Fatal("failed to listen", "error", err)
+	}
+
+	srv := &http.Server{Handler: r}
+	if opt.TLS.Enabled() {
+		tlsConfig, err := opt.TLS.Config()
+		if err != nil {
+			Fatal("failed to configure admin TLS", "error", err)
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
+	slog.Info("Serving stub admin", "address", httpURL(addr, srv.TLSConfig != nil))
+	serveHTTP(srv, lis)
+}
+
+func newRouter(opt Options) *chi.Mux {
 	r := chi.NewRouter()
-	r.Post("/add", addStub)
+	if opt.Token != "" || opt.BasicAuth != "" {
+		r.Use(authenticate(opt.Token, opt.BasicAuth))
+	}
+
 	r.Get("/", listStub)
+	r.Get("/requests", listRequests)
+	r.Get("/metrics", metricsHandler.ServeHTTP)
+	if opt.ReadOnly {
+		return r
+	}
+
+	r.Post("/add", addStub)
 	r.Post("/find", handleFindStub)
 	r.Get("/clear", handleClearStub)
 	r.Post("/reset", handleResetStub)
-	r.Get("/requests", listRequests)
+	r.Get("/export", handleExportStub)
+	r.Post("/import", handleImportStub)
+	r.Get("/services", handleListServices)
+	r.Post("/protos", handleUploadProtos)
+	r.Get("/health", listHealth)
+	r.Post("/health", handleSetHealth)
+	return r
+}
 
-	if opt.StubPath != "" {
-		count := readStubFromFile(opt.StubPath)
-		fmt.Printf("Loaded %d stubs from %s\n", count, opt.StubPath)
+// authenticate accepts requests carrying either the bearer token or the basic auth credentials
+func authenticate(token, basicAuth string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token != "" {
+				bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+				if ok && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			if basicAuth != "" {
+				user, password, ok := r.BasicAuth()
+				if ok && subtle.ConstantTimeCompare([]byte(user+":"+password), []byte(basicAuth)) == 1 {
+					next.ServeHTTP(w, r)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Basic realm="gripmock"`)
+			}
+
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, "Unauthorized")
+		})
 	}
-
-	fmt.Println("Serving stub admin on http://" + addr)
-	go func() {
-		err := http.ListenAndServe(addr, r)
-		log.Fatal(err)
-	}()
 }
 
 func responseError(err error, w http.ResponseWriter) {
 	w.WriteHeader(500)
 	if _, err = w.Write([]byte(err.Error())); err != nil {
-		log.Println("Error writing response: %w", err)
+		slog.Error("Error writing response", "error", err)
 	}
 }
 
@@ -69,13 +176,29 @@ type Input struct {
 	Matches         map[string]interface{} `json:"matches"`
 
 	Headers *InputHeaders `json:"headers,omitempty"`
+
+	// Any matches every request, the headers rules still apply
+	Any bool `json:"any,omitempty"`
+	// Default makes the stub answer the calls of the method no other stub matches,
+	// when the not found mode of the service is default
+	Default bool `json:"default,omitempty"`
 }
 
+// InputHeaders are the rules on the request metadata, values of -bin headers are base64 encoded.
+// Equals, EqualsUnordered, Contains and Matches apply to the first value of each header,
+// Any, All and Ordered to all of them.
 type InputHeaders struct {
 	Equals          map[string]string `json:"equals,omitempty"`
 	EqualsUnordered map[string]string `json:"equals_unordered,omitempty"`
 	Contains        map[string]string `json:"contains,omitempty"`
 	Matches         map[string]string `json:"matches,omitempty"`
+
+	// Any requires one of the listed values to be sent
+	Any map[string][]string `json:"any,omitempty"`
+	// All requires all of the listed values to be sent, in any order
+	All map[string][]string `json:"all,omitempty"`
+	// Ordered requires exactly the listed values to be sent, in the same order
+	Ordered map[string][]string `json:"ordered,omitempty"`
 }
 
 type Output struct {
@@ -84,6 +207,8 @@ type Output struct {
 	Code    *codes.Code            `json:"code,omitempty"`
 	Latency *time.Duration         `json:"latency,omitempty"`
 	Headers map[string]string      `json:"headers,omitempty"`
+	// HeaderValues are sent in addition to Headers, for headers with several values
+	HeaderValues map[string][]string `json:"header_values,omitempty"`
 }
 
 func addStub(w http.ResponseWriter, r *http.Request) {
@@ -113,14 +238,14 @@ func addStub(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if _, err = w.Write([]byte("Success add stub")); err != nil {
-		log.Println("Error writing response: %w", err)
+		slog.Error("Error writing response", "error", err)
 	}
 }
 
 func listStub(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(allStub()); err != nil {
-		log.Println("Error writing listStub response: %w", err)
+		slog.Error("Error writing listStub response", "error", err)
 	}
 }
 
@@ -133,6 +258,14 @@ func validateStub(stub *Stub) error {
 		return fmt.Errorf("method name can't be emtpy")
 	}
 
+	for _, name := range []string{stub.Service, stub.Method} {
+		if isPattern(name) {
+			if err := validatePattern(name); err != nil {
+				return err
+			}
+		}
+	}
+
 	switch {
 	case stub.Input.Contains != nil:
 		break
@@ -142,6 +275,10 @@ func validateStub(stub *Stub) error {
 		break
 	case stub.Input.Matches != nil:
 		break
+	case stub.Input.Any:
+		break
+	case stub.Input.Default:
+		break
 	default:
 		return fmt.Errorf("Input cannot be empty")
 	}
@@ -151,6 +288,10 @@ func validateStub(stub *Stub) error {
 	if stub.Output.Error == "" && stub.Output.Data == nil && stub.Output.Code == nil {
 		return fmt.Errorf("Output can't be empty")
 	}
+
+	if _, err := outputMetadata(&stub.Output); err != nil {
+		return fmt.Errorf("Output headers are invalid: %w", err)
+	}
 	return nil
 }
 
@@ -159,6 +300,8 @@ type findStubPayload struct {
 	Method  string                 `json:"method"`
 	Data    map[string]interface{} `json:"data"`
 	Headers map[string]string      `json:"headers,omitempty"`
+	// HeaderValues are all the values of the headers, Headers only holding the first one
+	HeaderValues map[string][]string `json:"header_values,omitempty"`
 }
 
 func handleFindStub(w http.ResponseWriter, r *http.Request) {
@@ -171,21 +314,21 @@ func handleFindStub(w http.ResponseWriter, r *http.Request) {
 
 	output, err := findStub(stub)
 	if err != nil {
-		log.Println(err)
+		slog.Warn("Stub not found", "service", stub.Service, "method", stub.Method, "error", err)
 		responseError(err, w)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(output); err != nil {
-		log.Println("Error writing handleFindStub response: %w", err)
+		slog.Error("Error writing handleFindStub response", "error", err)
 	}
 }
 
 func handleClearStub(w http.ResponseWriter, r *http.Request) {
 	clearStorage()
 	if _, err := w.Write([]byte("OK")); err != nil {
-		log.Println("Error writing handleClearStub response: %w", err)
+		slog.Error("Error writing handleClearStub response", "error", err)
 	}
 }
 
@@ -195,11 +338,11 @@ func handleResetStub(w http.ResponseWriter, r *http.Request) {
 		count := readStubFromFile(stubPath)
 		response := fmt.Sprintf("Stubs reset from files. Loaded %d stubs.", count)
 		if _, err := w.Write([]byte(response)); err != nil {
-			log.Println("Error writing handleResetStub response: %w", err)
+			slog.Error("Error writing handleResetStub response", "error", err)
 		}
 	} else {
 		if _, err := w.Write([]byte("No stub path configured")); err != nil {
-			log.Println("Error writing handleResetStub response: %w", err)
+			slog.Error("Error writing handleResetStub response", "error", err)
 		}
 	}
 }
@@ -208,3 +351,150 @@ func listRequests(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(allRequests())
 }
+
+func handleExportStub(w http.ResponseWriter, r *http.Request) {
+	stubs := exportStubs()
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(stubs); err != nil {
+			slog.Error("Error writing handleExportStub response", "error", err)
+		}
+		return
+	case archiveFormatTar:
+		w.Header().Set("Content-Type", "application/x-tar")
+	case archiveFormatZip:
+		w.Header().Set("Content-Type", "application/zip")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "unsupported export format %q, use json, tar or zip", format)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=stubs.%s", format))
+	if err := writeStubArchive(w, format, stubs); err != nil {
+		slog.Error("Error writing handleExportStub response", "error", err)
+	}
+}
+
+func handleImportStub(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		responseError(err, w)
+		return
+	}
+
+	stubs, err := readStubArchive(body)
+	if err != nil {
+		responseError(err, w)
+		return
+	}
+
+	if err := importStubs(stubs, r.URL.Query().Get("replace") == "true"); err != nil {
+		responseError(err, w)
+		return
+	}
+
+	response := fmt.Sprintf("Imported %d stubs.", len(stubs))
+	if _, err := w.Write([]byte(response)); err != nil {
+		slog.Error("Error writing handleImportStub response", "error", err)
+	}
+}
+
+func handleListServices(w http.ResponseWriter, r *http.Request) {
+	if listServices == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprint(w, "Listing services is not supported by this server")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(listServices()); err != nil {
+		slog.Error("Error writing handleListServices response", "error", err)
+	}
+}
+
+// handleUploadProtos accepts either a multipart form of .proto files, named by their
+// import path, and descriptor sets, or a single raw body. A raw body is a descriptor set
+// when sent as application/octet-stream, otherwise it is a .proto file named by ?name=.
+func handleUploadProtos(w http.ResponseWriter, r *http.Request) {
+	if protoLoader == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprint(w, "Uploading protos requires gripmock to run in dynamic mode")
+		return
+	}
+
+	sources := map[string]string{}
+	sets := [][]byte{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			responseError(err, w)
+			return
+		}
+		for _, files := range r.MultipartForm.File {
+			for _, fh := range files {
+				f, err := fh.Open()
+				if err != nil {
+					responseError(err, w)
+					return
+				}
+				byt, err := io.ReadAll(f)
+				f.Close()
+				if err != nil {
+					responseError(err, w)
+					return
+				}
+
+				if strings.HasSuffix(fh.Filename, ".proto") {
+					sources[fh.Filename] = string(byt)
+				} else {
+					sets = append(sets, byt)
+				}
+			}
+		}
+	} else {
+		byt, err := io.ReadAll(r.Body)
+		if err != nil {
+			responseError(err, w)
+			return
+		}
+
+		if r.Header.Get("Content-Type") == "application/octet-stream" {
+			sets = append(sets, byt)
+		} else if name := r.URL.Query().Get("name"); name != "" {
+			sources[name] = string(byt)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "name query parameter is required to upload a single .proto file")
+			return
+		}
+	}
+
+	services := []string{}
+	for _, set := range sets {
+		registered, err := protoLoader.RegisterDescriptorSet(set)
+		if err != nil {
+			responseError(err, w)
+			return
+		}
+		services = append(services, registered...)
+	}
+	if len(sources) > 0 {
+		registered, err := protoLoader.RegisterProtos(sources)
+		if err != nil {
+			responseError(err, w)
+			return
+		}
+		services = append(services, registered...)
+	}
+	InitHealth(services)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]string{"services": services}); err != nil {
+		slog.Error("Error writing handleUploadProtos response", "error", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -803,3 +804,173 @@ func TestStub(t *testing.T) {
 		})
 	}
 }
+
+func TestExportImport(t *testing.T) {
+	clearStorage()
+	stubs := []*Stub{
+		{
+			Service: "Service1",
+			Method:  "Method1",
+			Input:   Input{Equals: map[string]interface{}{"field1": "value1"}},
+			Output:  Output{Data: map[string]interface{}{"result1": "success1"}},
+		},
+		{
+			Service: "Service1",
+			Method:  "Method1",
+			Input:   Input{Contains: map[string]interface{}{"field1": "value2"}},
+			Output:  Output{Error: "failed"},
+		},
+		{
+			Service: "Service2",
+			Method:  "Method2",
+			Input:   Input{Matches: map[string]interface{}{"field2": "^value"}},
+			Output:  Output{Data: map[string]interface{}{"result2": "success2"}},
+		},
+	}
+	for _, s := range stubs {
+		require.NoError(t, storeStub(s))
+	}
+
+	for _, format := range []string{"json", "tar", "zip"} {
+		t.Run(format, func(t *testing.T) {
+			wrt := httptest.NewRecorder()
+			handleExportStub(wrt, httptest.NewRequest("GET", "/export?format="+format, nil))
+			require.Equal(t, http.StatusOK, wrt.Code)
+			exported := wrt.Body.Bytes()
+
+			clearStorage()
+			wrt = httptest.NewRecorder()
+			handleImportStub(wrt, httptest.NewRequest("POST", "/import", bytes.NewReader(exported)))
+			assert.Equal(t, "Imported 3 stubs.", wrt.Body.String())
+			assert.Equal(t, stubs, exportStubs())
+		})
+	}
+
+	t.Run("patterns", func(t *testing.T) {
+		clearStorage()
+		patterns := []*Stub{
+			{
+				Service: "*",
+				Method:  "Delete*",
+				Input:   Input{Any: true},
+				Output:  Output{Data: map[string]interface{}{}},
+			},
+			{
+				Service: "Service1",
+				Method:  "/^(Get|List)Items?$/",
+				Input:   Input{Any: true},
+				Output:  Output{Data: map[string]interface{}{"items": "all"}},
+			},
+			{
+				Service: "Service1",
+				Method:  "Get[AB]?",
+				Input:   Input{Any: true},
+				Output:  Output{Data: map[string]interface{}{"items": "ab"}},
+			},
+		}
+		for _, s := range patterns {
+			require.NoError(t, storeStub(s))
+		}
+
+		// one flat entry per service and method
+		files, err := groupStubFiles(exportStubs())
+		require.NoError(t, err)
+		require.Len(t, files, 3)
+		for _, file := range files {
+			assert.Equal(t, 1, strings.Count(file.name, "/"), file.name)
+			assert.NotContains(t, file.name, "*", file.name)
+			assert.NotContains(t, file.name, "?", file.name)
+			assert.NotContains(t, file.name, "[", file.name)
+		}
+
+		for _, format := range []string{"tar", "zip"} {
+			wrt := httptest.NewRecorder()
+			handleExportStub(wrt, httptest.NewRequest("GET", "/export?format="+format, nil))
+			require.Equal(t, http.StatusOK, wrt.Code)
+			exported := wrt.Body.Bytes()
+
+			clearStorage()
+			wrt = httptest.NewRecorder()
+			handleImportStub(wrt, httptest.NewRequest("POST", "/import", bytes.NewReader(exported)))
+			assert.Equal(t, "Imported 3 stubs.", wrt.Body.String(), format)
+			assert.ElementsMatch(t, patterns, exportStubs(), format)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		payload := `{"service":"Service3","method":"Method3","input":{"equals":{}},"output":{"data":{}}}`
+		wrt := httptest.NewRecorder()
+		handleImportStub(wrt, httptest.NewRequest("POST", "/import?replace=true", bytes.NewReader([]byte(payload))))
+		assert.Equal(t, "Imported 1 stubs.", wrt.Body.String())
+
+		exported := exportStubs()
+		require.Len(t, exported, 1)
+		assert.Equal(t, "Service3", exported[0].Service)
+	})
+
+	t.Run("invalid stubs are not imported", func(t *testing.T) {
+		// the second stub has no input, the first is not stored and Service3 is not cleared
+		payload := `[{"service":"Service4","method":"Method4","input":{"equals":{}},"output":{"data":{}}},` +
+			`{"service":"Service5","method":"Method5","output":{"data":{}}}]`
+		wrt := httptest.NewRecorder()
+		handleImportStub(wrt, httptest.NewRequest("POST", "/import?replace=true", bytes.NewReader([]byte(payload))))
+		assert.Equal(t, http.StatusInternalServerError, wrt.Code)
+		assert.Contains(t, wrt.Body.String(), "Service5/Method5")
+
+		exported := exportStubs()
+		require.Len(t, exported, 1)
+		assert.Equal(t, "Service3", exported[0].Service)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		wrt := httptest.NewRecorder()
+		handleExportStub(wrt, httptest.NewRequest("GET", "/export?format=rar", nil))
+		assert.Equal(t, http.StatusBadRequest, wrt.Code)
+	})
+}
+
+func TestRouterAuth(t *testing.T) {
+	clearStorage()
+	router := newRouter(Options{Token: "secret", BasicAuth: "admin:pass"})
+
+	serve := func(setup func(r *http.Request)) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		setup(req)
+		wrt := httptest.NewRecorder()
+		router.ServeHTTP(wrt, req)
+		return wrt.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(func(r *http.Request) {}))
+	assert.Equal(t, http.StatusUnauthorized, serve(func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }))
+	assert.Equal(t, http.StatusUnauthorized, serve(func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }))
+	assert.Equal(t, http.StatusOK, serve(func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }))
+	assert.Equal(t, http.StatusOK, serve(func(r *http.Request) { r.SetBasicAuth("admin", "pass") }))
+}
+
+func TestRouterReadOnly(t *testing.T) {
+	clearStorage()
+	router := newRouter(Options{ReadOnly: true})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/", nil),
+		httptest.NewRequest("GET", "/requests", nil),
+		httptest.NewRequest("GET", "/metrics", nil),
+	} {
+		wrt := httptest.NewRecorder()
+		router.ServeHTTP(wrt, req)
+		assert.Equal(t, http.StatusOK, wrt.Code, req.URL.Path)
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("POST", "/add", bytes.NewReader([]byte(`{}`))),
+		httptest.NewRequest("POST", "/find", bytes.NewReader([]byte(`{}`))),
+		httptest.NewRequest("GET", "/clear", nil),
+		httptest.NewRequest("POST", "/reset", nil),
+		httptest.NewRequest("POST", "/import", nil),
+	} {
+		wrt := httptest.NewRecorder()
+		router.ServeHTTP(wrt, req)
+		assert.Equal(t, http.StatusNotFound, wrt.Code, req.URL.Path)
+	}
+}
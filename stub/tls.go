@@ -0,0 +1,230 @@
+package stub
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TLSOptions configures TLS of a gripmock listener
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS, clients must present a certificate signed by it
+	ClientCAFile string
+	// GenerateDir generates a self-signed CA and server and client certificates signed by it
+	// into the directory, the server then uses the generated certificate.
+	// An existing ca.pem and ca-key.pem in the directory are reused.
+	GenerateDir string
+}
+
+// Enabled reports whether TLS is configured
+func (o TLSOptions) Enabled() bool {
+	return o.GenerateDir != "" || o.CertFile != "" || o.KeyFile != ""
+}
+
+// Config builds the server side TLS config
+func (o TLSOptions) Config() (*tls.Config, error) {
+	if o.GenerateDir != "" {
+		if err := generateCertificatesOnce(o.GenerateDir); err != nil {
+			return nil, fmt.Errorf("generate certificates: %w", err)
+		}
+		o.CertFile = filepath.Join(o.GenerateDir, "server.pem")
+		o.KeyFile = filepath.Join(o.GenerateDir, "server-key.pem")
+	}
+	if o.CertFile == "" || o.KeyFile == "" {
+		return nil, fmt.Errorf("both a certificate and a key file are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if o.ClientCAFile != "" {
+		pem, err := os.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in client CA %s", o.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+var (
+	generatedMx = sync.Mutex{}
+	// generated holds the outcome of the generation of each directory
+	generated = map[string]error{}
+)
+
+// generateCertificatesOnce generates the certificates of dir the first time only, so that the
+// listeners sharing the directory serve the same certificate that clients read from it
+func generateCertificatesOnce(dir string) error {
+	generatedMx.Lock()
+	defer generatedMx.Unlock()
+
+	key := filepath.Clean(dir)
+	if err, ok := generated[key]; ok {
+		return err
+	}
+	err := generateCertificates(dir)
+	generated[key] = err
+	return err
+}
+
+// generateCertificates writes ca.pem, ca-key.pem, server.pem, server-key.pem,
+// client.pem and client-key.pem into dir
+func generateCertificates(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	caCert, caKey, err := loadCA(dir)
+	if err != nil {
+		return err
+	}
+
+	if caCert == nil {
+		caCert, caKey, err = issueCertificate(&x509.Certificate{
+			Subject:               pkix.Name{CommonName: "GripMock CA"},
+			IsCA:                  true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+		}, nil, nil)
+		if err != nil {
+			return err
+		}
+		if err := writeCertificate(dir, "ca", caCert, caKey); err != nil {
+			return err
+		}
+	}
+
+	hosts := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	serverCert, serverKey, err := issueCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "GripMock Server"},
+		DNSNames:    hosts,
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, caCert, caKey)
+	if err != nil {
+		return err
+	}
+	if err := writeCertificate(dir, "server", serverCert, serverKey); err != nil {
+		return err
+	}
+
+	clientCert, clientKey, err := issueCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "GripMock Client"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, caCert, caKey)
+	if err != nil {
+		return err
+	}
+	return writeCertificate(dir, "client", clientCert, clientKey)
+}
+
+func loadCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, "ca-key.pem"))
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, fmt.Errorf("invalid CA in %s", dir)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, key, nil
+}
+
+// issueCertificate signs template with the parent, or self-signs it when parent is nil
+func issueCertificate(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().AddDate(1, 0, 0)
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, key, nil
+}
+
+func writeCertificate(dir, name string, cert *x509.Certificate, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0644); err != nil {
+		return err
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return os.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPEM, 0600)
+}
@@ -0,0 +1,95 @@
+package stub
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSOptions_Config(t *testing.T) {
+	dir := t.TempDir()
+	opt := TLSOptions{
+		GenerateDir:  dir,
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	require.True(t, opt.Enabled())
+
+	serverConfig, err := opt.Config()
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, serverConfig.ClientAuth)
+
+	caPEM, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(caPEM))
+
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem"))
+	require.NoError(t, err)
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				// echo a single byte once the handshake succeeds
+				buf := make([]byte, 1)
+				if _, err := conn.Read(buf); err == nil {
+					conn.Write(buf)
+				}
+			}()
+		}
+	}()
+
+	handshake := func(clientConfig *tls.Config) error {
+		conn, err := tls.Dial("tcp", lis.Addr().String(), clientConfig)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		// with TLS 1.3 a rejected client certificate only surfaces on the first read
+		if _, err := conn.Write([]byte{1}); err != nil {
+			return err
+		}
+		_, err = conn.Read(make([]byte, 1))
+		return err
+	}
+
+	assert.NoError(t, handshake(&tls.Config{
+		ServerName:   "localhost",
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert},
+	}))
+	assert.Error(t, handshake(&tls.Config{
+		ServerName: "localhost",
+		RootCAs:    roots,
+	}), "client certificate is required")
+
+	// the certificates are generated once for all the listeners
+	serverPEM, err := os.ReadFile(filepath.Join(dir, "server.pem"))
+	require.NoError(t, err)
+	_, err = opt.Config()
+	require.NoError(t, err)
+	for name, want := range map[string][]byte{"ca.pem": caPEM, "server.pem": serverPEM} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, name)
+	}
+
+	// a key without a certificate is an error rather than plaintext
+	keyOnly := TLSOptions{KeyFile: filepath.Join(dir, "server-key.pem")}
+	require.True(t, keyOnly.Enabled())
+	_, err = keyOnly.Config()
+	require.ErrorContains(t, err, "both a certificate and a key file are required")
+}
@@ -0,0 +1,65 @@
+package stub
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetupTracing exports the spans of the mocked calls over OTLP/gRPC to endpoint,
+// e.g. http://localhost:4317, an http:// endpoint is connected to without TLS.
+// Spans join the trace of the caller through the traceparent metadata.
+// The returned function flushes the pending spans.
+func SetupTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the service name
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName("gripmock")),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// traceLookup records the outcome of the stub lookup on the span of the call
+func traceLookup(ctx context.Context, match *stubMatch) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	if match == nil {
+		span.SetAttributes(attribute.Bool("gripmock.stub.matched", false))
+		return
+	}
+	span.SetAttributes(
+		attribute.Bool("gripmock.stub.matched", true),
+		attribute.String("gripmock.stub.rule", match.Rule),
+		attribute.Int("gripmock.stub.index", match.Index),
+	)
+}
@@ -0,0 +1,71 @@
+package stub
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	clearStorage()
+	require.NoError(t, storeStub(&Stub{
+		Service: "Tracing",
+		Method:  "Check",
+		Input:   Input{Contains: map[string]interface{}{"service": "traced"}},
+		Output:  Output{Data: map[string]interface{}{"status": "SERVING"}},
+	}))
+
+	// answers every call from the stubs, as the generated servers do
+	s := grpc.NewServer(append(ServerOptions(), grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		in, out := &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}
+		if err := stream.RecvMsg(in); err != nil {
+			return err
+		}
+		if err := FindStub(stream.Context(), "Tracing", "Check", nil, in, out); err != nil {
+			return err
+		}
+		return stream.SendMsg(out)
+	}))...)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	out := &healthpb.HealthCheckResponse{}
+	require.NoError(t, conn.Invoke(ctx, "/tracing.Tracing/Check", &healthpb.HealthCheckRequest{Service: "traced"}, out))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, out.GetStatus())
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "tracing.Tracing/Check", span.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	assert.Contains(t, span.Attributes, attribute.Bool("gripmock.stub.matched", true))
+	assert.Contains(t, span.Attributes, attribute.String("gripmock.stub.rule", "contains"))
+	assert.Contains(t, span.Attributes, attribute.Int("gripmock.stub.index", 0))
+}
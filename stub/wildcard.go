@@ -0,0 +1,111 @@
+package stub
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// isPattern reports whether a service or method name of a stub matches several names:
+// a glob such as List* or a regular expression between slashes such as /^(Get|List)/
+func isPattern(name string) bool {
+	return isRegexp(name) || strings.ContainsAny(name, "*?[")
+}
+
+func isRegexp(name string) bool {
+	return len(name) > 2 && strings.HasPrefix(name, "/") && strings.HasSuffix(name, "/")
+}
+
+func validatePattern(name string) error {
+	if isRegexp(name) {
+		if _, err := regexp.Compile(name[1 : len(name)-1]); err != nil {
+			return fmt.Errorf("invalid regular expression %s: %w", name, err)
+		}
+		return nil
+	}
+	if _, err := path.Match(name, ""); err != nil {
+		return fmt.Errorf("invalid pattern %s: %w", name, err)
+	}
+	return nil
+}
+
+// regexps are the compiled regular expressions of the stored stubs, guarded by mx.
+// They are dropped along with the stubs.
+var regexps = map[string]*regexp.Regexp{}
+
+// compilePattern compiles the name once when it is a regular expression, mx must be held.
+// Patterns are validated before stubs are stored.
+func compilePattern(name string) {
+	if !isRegexp(name) {
+		return
+	}
+	if _, ok := regexps[name]; ok {
+		return
+	}
+	if re, err := regexp.Compile(name[1 : len(name)-1]); err == nil {
+		regexps[name] = re
+	}
+}
+
+// patternMatches reports whether the pattern matches name, mx must be held
+func patternMatches(pattern, name string) bool {
+	if isRegexp(pattern) {
+		re, ok := regexps[pattern]
+		return ok && re.MatchString(name)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// serviceMatches reports whether the service name of a stub, a pattern or not, designates
+// the fully qualified service, by its full or short name
+func serviceMatches(stored, service string) bool {
+	short := service[strings.LastIndex(service, ".")+1:]
+	if !isPattern(stored) {
+		return stored == service || stored == short
+	}
+	return patternMatches(stored, service) || patternMatches(stored, short)
+}
+
+func methodMatches(stored, method string) bool {
+	if !isPattern(stored) {
+		return strings.EqualFold(stored, method)
+	}
+	return patternMatches(stored, method)
+}
+
+// wildcardStubs returns the stubs whose service or method name is a pattern matching the method,
+// sorted by service and method names for a stable matching order
+func (sm stubMapping) wildcardStubs(service, method string) []storage {
+	services := make([]string, 0, len(sm))
+	for name := range sm {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+
+	stubs := []storage{}
+	for _, storedService := range services {
+		if !serviceMatches(storedService, service) {
+			continue
+		}
+
+		methods := make([]string, 0, len(sm[storedService]))
+		for name := range sm[storedService] {
+			methods = append(methods, name)
+		}
+		sort.Strings(methods)
+
+		for _, storedMethod := range methods {
+			if !isPattern(storedService) && !isPattern(storedMethod) {
+				// stubs of the method itself
+				continue
+			}
+			if methodMatches(storedMethod, method) {
+				stubs = append(stubs, sm[storedService][storedMethod]...)
+			}
+		}
+	}
+	return stubs
+}
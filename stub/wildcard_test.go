@@ -0,0 +1,156 @@
+package stub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWildcardStubs(t *testing.T) {
+	clearStorage()
+	defer clearStorage()
+
+	stubs := []*Stub{
+		{
+			Service: "user.UserService",
+			Method:  "DeleteUser",
+			Input:   Input{Equals: map[string]interface{}{"id": "admin"}},
+			Output:  Output{Error: "admin can't be deleted"},
+		},
+		{
+			Service: "*",
+			Method:  "Delete*",
+			Input:   Input{Any: true},
+			Output:  Output{Data: map[string]interface{}{}},
+		},
+		{
+			Service: "AuditService",
+			Method:  "*",
+			Input:   Input{Any: true, Headers: &InputHeaders{Equals: map[string]string{"tenant": "acme"}}},
+			Output:  Output{Data: map[string]interface{}{"ok": true}},
+		},
+		{
+			Service: "/^shop\\.v[0-9]+\\.Shop$/",
+			Method:  "/^(Get|List)Items?$/",
+			Input:   Input{Any: true},
+			Output:  Output{Data: map[string]interface{}{"items": []interface{}{}}},
+		},
+	}
+	for _, stub := range stubs {
+		require.NoError(t, StoreStub(stub))
+	}
+	// patterns are stored as they are
+	require.Contains(t, allStub()["*"], "Delete*")
+
+	tests := []struct {
+		name    string
+		input   *findStubPayload
+		want    *Output
+		wantErr bool
+	}{
+		{
+			name:  "exact stubs first",
+			input: &findStubPayload{Service: "user.UserService", Method: "DeleteUser", Data: map[string]interface{}{"id": "admin"}},
+			want:  &stubs[0].Output,
+		},
+		{
+			name:  "method glob",
+			input: &findStubPayload{Service: "user.UserService", Method: "DeleteUser", Data: map[string]interface{}{"id": "1"}},
+			want:  &stubs[1].Output,
+		},
+		{
+			name:  "method glob of another service",
+			input: &findStubPayload{Service: "shop.v1.Shop", Method: "DeleteItem", Data: map[string]interface{}{}},
+			want:  &stubs[1].Output,
+		},
+		{
+			name:  "short service name with headers",
+			input: &findStubPayload{Service: "audit.v1.AuditService", Method: "Record", Data: map[string]interface{}{"event": "login"}, Headers: map[string]string{"tenant": "acme"}},
+			want:  &stubs[2].Output,
+		},
+		{
+			name:    "headers still apply",
+			input:   &findStubPayload{Service: "audit.v1.AuditService", Method: "Record", Data: map[string]interface{}{}},
+			wantErr: true,
+		},
+		{
+			name:  "regular expressions",
+			input: &findStubPayload{Service: "shop.v2.Shop", Method: "ListItems", Data: map[string]interface{}{}},
+			want:  &stubs[3].Output,
+		},
+		{
+			name:    "unmatched",
+			input:   &findStubPayload{Service: "shop.v2.Shop", Method: "CreateItem", Data: map[string]interface{}{}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findStub(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAnyInputLast(t *testing.T) {
+	clearStorage()
+	defer clearStorage()
+
+	// the catch-all is stored first, on the method itself
+	require.NoError(t, StoreStub(&Stub{
+		Service: "hello.Greeter",
+		Method:  "SayHello",
+		Input:   Input{Any: true},
+		Output:  Output{Data: map[string]interface{}{"message": "Hello stranger"}},
+	}))
+	require.NoError(t, StoreStub(&Stub{
+		Service: "*",
+		Method:  "SayHello",
+		Input:   Input{Equals: map[string]interface{}{"name": "tokopedia"}},
+		Output:  Output{Data: map[string]interface{}{"message": "Hello Tokopedia"}},
+	}))
+
+	find := func(name string) interface{} {
+		out, err := findStub(&findStubPayload{Service: "hello.Greeter", Method: "SayHello", Data: map[string]interface{}{"name": name}})
+		require.NoError(t, err)
+		return out.Data["message"]
+	}
+	assert.Equal(t, "Hello Tokopedia", find("tokopedia"))
+	assert.Equal(t, "Hello stranger", find("unknown"))
+}
+
+func TestValidateStubPatterns(t *testing.T) {
+	output := Output{Data: map[string]interface{}{}}
+
+	require.NoError(t, validateStub(&Stub{Service: "*", Method: "*", Input: Input{Any: true}, Output: output}))
+	require.ErrorContains(t, validateStub(&Stub{Service: "*", Method: "List[", Input: Input{Any: true}, Output: output}), "invalid pattern List[")
+	require.ErrorContains(t, validateStub(&Stub{Service: "/(/", Method: "List", Input: Input{Any: true}, Output: output}), "invalid regular expression /(/")
+	require.ErrorContains(t, validateStub(&Stub{Service: "*", Method: "*", Output: output}), "Input cannot be empty")
+}
+
+func TestRegexpsCleared(t *testing.T) {
+	clearStorage()
+	defer clearStorage()
+
+	stub := func(method string) *Stub {
+		return &Stub{Service: "*", Method: method, Input: Input{Any: true}, Output: Output{Data: map[string]interface{}{}}}
+	}
+	require.NoError(t, StoreStub(stub("/^Get/")))
+	require.NoError(t, StoreStub(stub("/^List/")))
+	assert.Len(t, regexps, 2)
+
+	// the compiled regular expressions go with the stubs
+	clearStorage()
+	assert.Empty(t, regexps)
+
+	require.NoError(t, StoreStub(stub("/^Get/")))
+	require.NoError(t, importStubs([]*Stub{stub("/^List/")}, true))
+	assert.Len(t, regexps, 1)
+	assert.Contains(t, regexps, "/^List/")
+}